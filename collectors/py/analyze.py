@@ -162,6 +162,8 @@ def write_report_ru(dst: Path, ctx: Dict[str, Any]) -> None:
     lines.append("## Контекст запуска")
     lines.append("")
     lines.append(f"- Hostname: {ctx.get('hostname', '?')}")
+    if ctx.get("build_info"):
+        lines.append(f"- Build: {ctx.get('build_info')}")
     lines.append(f"- Started (UTC): {ctx.get('started_utc', '?')}")
     lines.append(f"- Mode: {ctx.get('mode', '?')}")
     lines.append(f"- Profile: {ctx.get('profile', '?')}")
@@ -224,6 +226,8 @@ def write_report_en(dst: Path, ctx: Dict[str, Any]) -> None:
     lines.append("## Run context")
     lines.append("")
     lines.append(f"- Hostname: {ctx.get('hostname', '?')}")
+    if ctx.get("build_info"):
+        lines.append(f"- Build: {ctx.get('build_info')}")
     lines.append(f"- Started (UTC): {ctx.get('started_utc', '?')}")
     lines.append(f"- Mode: {ctx.get('mode', '?')}")
     lines.append(f"- Profile: {ctx.get('profile', '?')}")
@@ -295,6 +299,7 @@ def main() -> int:
 
     # Meta
     ctx["tool_version"] = read_first_line(workdir / "meta" / "tool_version.txt")
+    ctx["build_info"] = read_first_line(workdir / "meta" / "build_info.txt")
     ctx["hostname"] = read_first_line(workdir / "meta" / "hostname.txt")
     ctx["started_utc"] = read_first_line(workdir / "meta" / "started_utc.txt")
     ctx["outbase"] = read_first_line(workdir / "meta" / "outbase.txt")
@@ -347,6 +352,11 @@ def main() -> int:
     if ctx.get("opkg_storage_hint") == "internal":
         warnings.append("OPKG (/opt) seems to be on internal storage. Consider RAM output to reduce NAND wear.")
 
+    # Findings from the python collector (collectors/py/probe.py)
+    probe = safe_json_loads(read_text(analysis / "python_probe.json", max_bytes=4_000_000))
+    for w in probe.get("warnings") or []:
+        warnings.append(str(w))
+
     ctx["warnings"] = warnings
 
     # Write reports
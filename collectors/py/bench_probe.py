@@ -0,0 +1,90 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+"""Timing baseline for the large-file /proc parsers in probe.py (stdlib only).
+
+Generates synthetic nf_conntrack, /proc/net/tcp and /proc/net/dev files with
+--rows entries each, then reports the best time per call over --repeat runs and
+the peak memory allocated by one call (tracemalloc).
+
+Run: python3 collectors/py/bench_probe.py [--rows 10000] [--repeat 5]
+"""
+
+from __future__ import annotations
+
+import argparse
+import sys
+import tempfile
+import timeit
+import tracemalloc
+from pathlib import Path
+from typing import Any, Callable
+
+sys.path.insert(0, str(Path(__file__).resolve().parent))
+
+import probe  # noqa: E402
+from test_probe import TCP_HEADER, tcp_row  # noqa: E402
+
+NET_DEV_HEADER = (
+    "Inter-|   Receive                                                |  Transmit\n"
+    " face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n"
+)
+
+
+def conntrack_rows(n: int) -> str:
+    out = []
+    for i in range(n):
+        a, b = divmod(i, 250)
+        src, dst, sport = f"192.168.{a % 250}.{b + 1}", f"93.184.{a % 250}.{b + 1}", 30000 + i % 30000
+        if i % 3:
+            out.append(f"ipv4     2 tcp      6 431999 ESTABLISHED src={src} dst={dst} sport={sport} dport=443 "
+                       f"packets=12 bytes=3400 src={dst} dst=203.0.113.7 sport=443 dport={sport} "
+                       f"packets=10 bytes=8800 [ASSURED] mark=0 zone=0 use=2\n")
+        else:
+            out.append(f"ipv4     2 udp      17 29 src={src} dst=8.8.8.8 sport={sport} dport=53 "
+                       f"packets=1 bytes=60 src=8.8.8.8 dst=203.0.113.7 sport=53 dport={sport} "
+                       f"packets=1 bytes=120 mark=0 zone=0 use=2\n")
+    return "".join(out)
+
+
+def tcp_rows(n: int) -> str:
+    return TCP_HEADER + "".join(
+        tcp_row(i, f"192.168.{i // 250 % 250}.{i % 250 + 1}", 1024 + i % 60000, "203.0.113.7", 443, "01")
+        for i in range(n))
+
+
+def net_dev_rows(n: int) -> str:
+    return NET_DEV_HEADER + "".join(
+        f"{'eth%d' % i:>6}: {i * 1500} {i} 0 0 0 0 0 {i % 7} {i * 900} {i} 0 0 0 0 0 0\n" for i in range(n))
+
+
+def bench(name: str, fn: Callable[[], Any], repeat: int) -> None:
+    rows = len(fn())
+    best = min(timeit.repeat(fn, number=1, repeat=repeat))
+    tracemalloc.start()
+    fn()
+    peak = tracemalloc.get_traced_memory()[1]
+    tracemalloc.stop()
+    print(f"{name:<18} {rows:>7} rows {best * 1000:>9.2f} ms/op {peak / 1024:>9.0f} KiB peak")
+
+
+def main() -> int:
+    ap = argparse.ArgumentParser()
+    ap.add_argument("--rows", type=int, default=10000)
+    ap.add_argument("--repeat", type=int, default=5)
+    args = ap.parse_args()
+
+    with tempfile.TemporaryDirectory() as d:
+        tmp = Path(d)
+        (tmp / "nf_conntrack").write_text(conntrack_rows(args.rows), encoding="utf-8")
+        (tmp / "tcp").write_text(tcp_rows(args.rows), encoding="utf-8")
+        (tmp / "dev").write_text(net_dev_rows(args.rows), encoding="utf-8")
+        # parse_net_dev reads through read_text's default 1 MB cap, so with a
+        # much larger --rows the dev file is cut short; "rows" is what was parsed
+        bench("parse_conntrack", lambda: probe.parse_conntrack(tmp / "nf_conntrack"), args.repeat)
+        bench("proc_net_sockets", lambda: probe.proc_net_sockets(tmp / "tcp"), args.repeat)
+        bench("parse_net_dev", lambda: probe.parse_net_dev(tmp / "dev"), args.repeat)
+    return 0
+
+
+if __name__ == "__main__":
+    raise SystemExit(main())
@@ -8,8 +8,13 @@ This is NOT required for the core tool, but can enrich analysis data.
 from __future__ import annotations
 
 import argparse
+import hashlib
 import json
 import re
+import socket
+import struct
+import sys
+from datetime import datetime, timedelta, timezone
 from pathlib import Path
 from typing import Any, Dict, List, Tuple
 
@@ -55,6 +60,69 @@ def parse_metrics(p: Path) -> Dict[str, Any]:
     return {"cpu": summary(cpu), "mem": summary(mem), "load": summary(load)}
 
 
+def conntrack_growth(p: Path) -> float:
+    # (last - first) / elapsed over the run's metrics samples (ts ... conntrack);
+    # -1 when fewer than two samples carry a conntrack count.
+    samples: List[Tuple[float, int]] = []
+    for line in read_text(p, max_bytes=2_000_000).splitlines():
+        parts = line.split("\t")
+        if line.startswith("#") or len(parts) < 5 or to_int(parts[4]) < 0:
+            continue
+        try:
+            ts = datetime.strptime(parts[0], "%Y-%m-%dT%H:%M:%SZ").timestamp()
+        except ValueError:
+            continue
+        samples.append((ts, to_int(parts[4])))
+    if len(samples) < 2 or samples[-1][0] <= samples[0][0]:
+        return -1.0
+    return round((samples[-1][1] - samples[0][1]) / (samples[-1][0] - samples[0][0]), 3)
+
+
+def ipv6_mcast_bandwidth(samples: Path, cache: List[Dict[str, Any]], vifs: List[Dict[str, Any]], uptime: int) -> Dict[str, float]:
+    # Bytes/s per interface. With two or more sampler rows (meta/mcast6_bytes.tsv:
+    # ts vif bytes) this is the real rate over the run; otherwise the MFC byte
+    # totals divided by uptime, a rough average since boot because entries
+    # expire and are re-created. The (S,G) bytes count for the iif and every oif.
+    names = {v["idx"]: v["iface"] for v in vifs}
+    series: Dict[int, List[Tuple[float, int]]] = {}
+    for line in read_text(samples, max_bytes=2_000_000).splitlines():
+        parts = line.split("\t")
+        if len(parts) != 3:
+            continue
+        try:
+            ts = datetime.strptime(parts[0], "%Y-%m-%dT%H:%M:%SZ").timestamp()
+            series.setdefault(int(parts[1]), []).append((ts, int(parts[2])))
+        except ValueError:
+            continue
+    rates: Dict[str, float] = {}
+    for vif, pts in series.items():
+        if len(pts) >= 2 and pts[-1][0] > pts[0][0]:
+            rates[names.get(vif, f"vif{vif}")] = round(max(pts[-1][1] - pts[0][1], 0) / (pts[-1][0] - pts[0][0]), 1)
+    if rates or uptime <= 0:
+        return rates
+    totals: Dict[int, int] = {}
+    for e in cache:
+        if e["iif"] == 65535 or e["bytes"] < 0:
+            continue
+        for vif in [e["iif"]] + [to_int(o.split(":")[0]) for o in e["oifs"]]:
+            totals[vif] = totals.get(vif, 0) + e["bytes"]
+    return {names.get(v, f"vif{v}"): round(b / uptime, 1) for v, b in sorted(totals.items())}
+
+
+def ip_forward_drops(p: Path) -> List[str]:
+    # timestamps of metrics samples where ip_forward went from 1 to 0
+    drops: List[str] = []
+    prev = ""
+    for line in read_text(p, max_bytes=2_000_000).splitlines():
+        parts = line.split("\t")
+        if line.startswith("#") or len(parts) < 6:
+            continue
+        if prev == "1" and parts[5] == "0":
+            drops.append(parts[0])
+        prev = parts[5]
+    return drops
+
+
 def dmesg_signals(p: Path) -> Dict[str, Any]:
     txt = read_text(p, max_bytes=1_000_000)
     err = 0
@@ -68,9 +136,1715 @@ def dmesg_signals(p: Path) -> Dict[str, Any]:
     return {"error_like_lines": err, "warn_like_lines": warn}
 
 
+XT_RECENT_RE = re.compile(r"^src=(\S+)\s+ttl:\s*(\d+)\s+last_seen:\s*(\d+)\s+oldest_pkt:\s*(\d+)\s*(.*)$")
+
+
+def parse_xt_recent(d: Path) -> Dict[str, List[Dict[str, Any]]]:
+    # One file per set; timestamps are raw jiffies as printed by the kernel.
+    sets: Dict[str, List[Dict[str, Any]]] = {}
+    if not d.is_dir():
+        return sets
+    for p in sorted(d.iterdir()):
+        if not p.is_file():
+            continue
+        entries: List[Dict[str, Any]] = []
+        for line in read_text(p).splitlines():
+            m = XT_RECENT_RE.match(line.strip())
+            if not m:
+                continue
+            stamps = [int(x) for x in re.findall(r"\d+", m.group(5))]
+            entries.append(
+                {
+                    "addr": m.group(1),
+                    "ttl": int(m.group(2)),
+                    "last_seen": int(m.group(3)),
+                    "oldest_pkt": int(m.group(4)),
+                    "timestamps": stamps,
+                }
+            )
+        sets[p.name] = entries
+    return sets
+
+
+def ipvs_endpoint(s: str) -> Tuple[str, str]:
+    # "C0A80001:0050" (IPv4, host order hex) or "[2001:db8::1]:0050"
+    addr, _, port = s.rpartition(":")
+    try:
+        port = str(int(port, 16))
+    except ValueError:
+        pass
+    if addr.startswith("["):
+        return addr.strip("[]"), port
+    try:
+        n = int(addr, 16)
+        addr = ".".join(str((n >> sh) & 0xFF) for sh in (24, 16, 8, 0))
+    except ValueError:
+        pass
+    return addr, port
+
+
+def parse_ipvs(p: Path) -> List[Dict[str, Any]]:
+    # Virtual services with their real servers ("->" lines) nested underneath.
+    services: List[Dict[str, Any]] = []
+    cur: Dict[str, Any] = {}
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if not parts or parts[0] in ("IP", "Prot"):
+            continue
+        if parts[0] == "->":
+            if not cur or len(parts) < 6 or parts[1] == "RemoteAddress:Port":
+                continue
+            addr, port = ipvs_endpoint(parts[1])
+            try:
+                cur["real_servers"].append(
+                    {
+                        "addr": addr,
+                        "port": port,
+                        "forward": parts[2],
+                        "weight": int(parts[3]),
+                        "active_conn": int(parts[4]),
+                        "inact_conn": int(parts[5]),
+                    }
+                )
+            except ValueError:
+                continue
+            continue
+        if len(parts) < 3:
+            continue
+        if parts[0] == "FWM":
+            addr, port = parts[1], ""
+        else:
+            addr, port = ipvs_endpoint(parts[1])
+        cur = {
+            "proto": parts[0],
+            "addr": addr,
+            "port": port,
+            "scheduler": parts[2],
+            "flags": " ".join(parts[3:]),
+            "real_servers": [],
+        }
+        services.append(cur)
+    return services
+
+
+def parse_ipvs_percpu(p: Path) -> List[Dict[str, int]]:
+    # CPU Conns InPkts OutPkts InBytes OutBytes, all hex including the CPU
+    # number ("%3X", so CPU 10 is "A"); the "~" row is the total
+    stats: List[Dict[str, int]] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 6 or parts[0] == "~":
+            continue
+        try:
+            cpu = int(parts[0], 16)
+            v = [int(x, 16) for x in parts[1:6]]
+        except ValueError:
+            continue
+        stats.append(
+            {
+                "cpu": cpu,
+                "connections": v[0],
+                "in_packets": v[1],
+                "out_packets": v[2],
+                "in_bytes": v[3],
+                "out_bytes": v[4],
+            }
+        )
+    return stats
+
+
+def ipvs_addr(h: str) -> str:
+    # IPv4 as host-order hex, IPv6 already printed in textual form
+    if ":" in h:
+        return h
+    addr, _ = ipvs_endpoint(h + ":0")
+    return addr
+
+
+def parse_ipvs_conns(p: Path) -> List[Dict[str, Any]]:
+    # Pro FromIP FPrt ToIP TPrt DestIP DPrt State Expires [PEName PEData]
+    conns: List[Dict[str, Any]] = []
+    for line in read_text(p, max_bytes=16_000_000).splitlines():
+        parts = line.split()
+        if len(parts) < 9 or parts[0] == "Pro":
+            continue
+        try:
+            conns.append(
+                {
+                    "proto": parts[0],
+                    "client_addr": ipvs_addr(parts[1]),
+                    "client_port": str(int(parts[2], 16)),
+                    "vaddr": ipvs_addr(parts[3]),
+                    "vport": str(int(parts[4], 16)),
+                    "daddr": ipvs_addr(parts[5]),
+                    "dport": str(int(parts[6], 16)),
+                    "state": parts[7],
+                    "timeout_ms": int(parts[8]) * 1000,
+                }
+            )
+        except ValueError:
+            continue
+    return conns
+
+
+def ipvs_client_counts(conns: List[Dict[str, Any]], top: int) -> Dict[str, int]:
+    # connections per client address; top <= 0 keeps all clients
+    counts: Dict[str, int] = {}
+    for c in conns:
+        counts[c["client_addr"]] = counts.get(c["client_addr"], 0) + 1
+    ranked = sorted(counts.items(), key=lambda kv: (-kv[1], kv[0]))
+    if top > 0:
+        ranked = ranked[:top]
+    return dict(ranked)
+
+
+def parse_ipvs_timeouts(p: Path) -> Dict[str, int]:
+    # ipvsadm -L --timeout: "Timeout (tcp tcpfin udp): 900 120 300"
+    out = {"tcp_timeout_sec": -1, "tcp_fin_timeout_sec": -1, "udp_timeout_sec": -1}
+    m = re.search(r"Timeout \(tcp tcpfin udp\):\s*(\d+)\s+(\d+)\s+(\d+)", read_text(p))
+    if m:
+        out["tcp_timeout_sec"], out["tcp_fin_timeout_sec"], out["udp_timeout_sec"] = (int(x) for x in m.groups())
+    return out
+
+
+def lblc_cache_size(p: Path) -> int:
+    # -1 when the kernel has no such table; the first line is a header
+    if not p.is_file():
+        return -1
+    return max(len([l for l in read_text(p, max_bytes=8_000_000).splitlines() if l.strip()]) - 1, 0)
+
+
+def parse_ipvs_apps(p: Path) -> List[str]:
+    # "prot port usecnt name" per registered application helper (e.g. ip_vs_ftp)
+    apps: List[str] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) >= 4 and parts[0] != "prot":
+            apps.append(f"{parts[0]}/{parts[1]} {parts[3]}")
+    return apps
+
+
+def ipvs_sync_state(p: Path) -> str:
+    # ipvsadm -L --daemon: "master sync daemon (mcast=eth0, syncid=1)", one line per running role
+    roles = [r for r in ("master", "backup") if re.search(rf"^{r} sync daemon", read_text(p), re.M)]
+    return ",".join(roles) or "none"
+
+
+def parse_flow_labels(p: Path) -> List[Dict[str, Any]]:
+    # Label S Owner Users Linger Expires Dst Opt (linger/expires in seconds)
+    labels: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 7 or parts[0] == "Label":
+            continue
+        try:
+            labels.append(
+                {
+                    "label": parts[0],
+                    "share": int(parts[1]),
+                    "owner": parts[2],
+                    "users": int(parts[3]),
+                    "linger": int(parts[4]) > 0,
+                    "expires_ms": int(parts[5]) * 1000,
+                    "dst": parts[6],
+                }
+            )
+        except ValueError:
+            continue
+    return labels
+
+
+TCP_STATES = {
+    "01": "ESTABLISHED",
+    "02": "SYN_SENT",
+    "03": "SYN_RECV",
+    "04": "FIN_WAIT1",
+    "05": "FIN_WAIT2",
+    "06": "TIME_WAIT",
+    "07": "CLOSE",
+    "08": "CLOSE_WAIT",
+    "09": "LAST_ACK",
+    "0A": "LISTEN",
+    "0B": "CLOSING",
+    "0C": "NEW_SYN_RECV",
+}
+
+
+def proc_net_sockets(p: Path) -> List[List[str]]:
+    # Rows of /proc/net/{tcp,udp,raw}[6] split on whitespace, header skipped.
+    rows: List[List[str]] = []
+    for line in read_text(p, max_bytes=8_000_000).splitlines():
+        parts = line.split()
+        if len(parts) < 10 or parts[0] == "sl":
+            continue
+        rows.append(parts)
+    return rows
+
+
+def hex_addr(h: str) -> str:
+    # /proc/net/{tcp,udp}* print each 32-bit word of the address in host byte order.
+    # Anything but 8 or 32 hex digits ("-1", signs, odd lengths) is returned as is.
+    if len(h) not in (8, 32) or not re.fullmatch(r"[0-9A-Fa-f]+", h):
+        return h
+    raw = b"".join(int(h[i:i + 8], 16).to_bytes(4, sys.byteorder) for i in range(0, len(h), 8))
+    return socket.inet_ntop(socket.AF_INET if len(raw) == 4 else socket.AF_INET6, raw)
+
+
+def hex_endpoint(s: str) -> Tuple[str, int]:
+    addr, _, port = s.partition(":")
+    try:
+        return hex_addr(addr), int(port, 16)
+    except ValueError:
+        return hex_addr(addr), 0
+
+
+def tcp_connections(net: Path, limit: int) -> List[Dict[str, Any]]:
+    conns: List[Dict[str, Any]] = []
+    for name in ("tcp", "tcp6"):
+        for parts in proc_net_sockets(net / name):
+            if len(conns) >= limit:
+                return conns
+            laddr, lport = hex_endpoint(parts[1])
+            raddr, rport = hex_endpoint(parts[2])
+            try:
+                uid = int(parts[7])
+            except ValueError:
+                uid = -1
+            conns.append(
+                {
+                    "local_addr": laddr,
+                    "local_port": lport,
+                    "remote_addr": raddr,
+                    "remote_port": rport,
+                    "state": TCP_STATES.get(parts[3].upper(), parts[3]),
+                    "uid": uid,
+                }
+            )
+    return conns
+
+
+# Daemons that normally hold raw sockets on KeeneticOS/Entware.
+RAW_SOCKET_OWNERS = {"ndm", "ping", "ping6", "arping", "traceroute", "igmpproxy", "mcproxy", "udpxy", "zebra", "bird", "dnsmasq"}
+
+
+def parse_socket_inodes(p: Path) -> Dict[str, Tuple[int, str]]:
+    owners: Dict[str, Tuple[int, str]] = {}
+    for line in read_text(p, max_bytes=8_000_000).splitlines():
+        if not line or line.startswith("#"):
+            continue
+        parts = line.split("\t")
+        if len(parts) < 3:
+            continue
+        owners.setdefault(parts[2], (to_int(parts[0]), parts[1]))
+    return owners
+
+
+def raw_sockets(net: Path, owners: Dict[str, Tuple[int, str]]) -> List[Dict[str, Any]]:
+    # For raw sockets the "port" half of local_address is the IP protocol number.
+    socks: List[Dict[str, Any]] = []
+    for name in ("raw", "raw6"):
+        for parts in proc_net_sockets(net / name):
+            addr, proto = hex_endpoint(parts[1])
+            pid, comm = owners.get(parts[9], (-1, ""))
+            socks.append(
+                {
+                    "family": "inet6" if name == "raw6" else "inet",
+                    "local_addr": addr,
+                    "protocol": proto,
+                    "uid": to_int(parts[7]),
+                    "inode": parts[9],
+                    "pid": pid,
+                    "comm": comm,
+                }
+            )
+    return socks
+
+
+def udp_source_ports(net: Path, top: int) -> Dict[str, int]:
+    # sockets per local port over udp + udp6, any state; top <= 0 keeps all ports
+    counts: Dict[int, int] = {}
+    for name in ("udp", "udp6"):
+        for parts in proc_net_sockets(net / name):
+            _, port = hex_endpoint(parts[1])
+            counts[port] = counts.get(port, 0) + 1
+    ranked = sorted(counts.items(), key=lambda kv: (-kv[1], kv[0]))
+    if top > 0:
+        ranked = ranked[:top]
+    return {str(port): n for port, n in ranked}
+
+
+def tcp_queue_stats(net: Path, high: int = 65536) -> Dict[str, int]:
+    # field 4 is "tx_queue:rx_queue" (hex bytes; for LISTEN sockets rx is the accept backlog)
+    st = {"max_snd": 0, "max_rcv": 0, "high_rcv": 0}
+    for name in ("tcp", "tcp6"):
+        for parts in proc_net_sockets(net / name):
+            tx, _, rx = parts[4].partition(":")
+            try:
+                tq, rq = int(tx, 16), int(rx, 16)
+            except ValueError:
+                continue
+            st["max_snd"] = max(st["max_snd"], tq)
+            st["max_rcv"] = max(st["max_rcv"], rq)
+            if rq > high:
+                st["high_rcv"] += 1
+    return st
+
+
+def tcp_listeners(net: Path) -> Tuple[List[Dict[str, Any]], List[Dict[str, Any]]]:
+    # LISTEN sockets split into wildcard (0.0.0.0 / ::) and address-specific binds
+    wildcard: List[Dict[str, Any]] = []
+    specific: List[Dict[str, Any]] = []
+    for name in ("tcp", "tcp6"):
+        for parts in proc_net_sockets(net / name):
+            if parts[3].upper() != "0A":
+                continue
+            addr, port = hex_endpoint(parts[1])
+            entry = {"proto": name, "addr": addr, "port": port}
+            (wildcard if addr in ("0.0.0.0", "::") else specific).append(entry)
+    key = lambda e: (e["port"], e["proto"], e["addr"])
+    return sorted(wildcard, key=key), sorted(specific, key=key)
+
+
+def tcp_state_count(p: Path) -> Dict[str, int]:
+    counts: Dict[str, int] = {}
+    for parts in proc_net_sockets(p):
+        st = TCP_STATES.get(parts[3].upper(), parts[3])
+        counts[st] = counts.get(st, 0) + 1
+    return counts
+
+
+def raw_ipv6(h: str) -> str:
+    # ipv6_route / if_inet6 print the address bytes in network order.
+    try:
+        return socket.inet_ntop(socket.AF_INET6, bytes.fromhex(h))
+    except (ValueError, OSError):
+        return h
+
+
+RTF_FLAGS = [
+    (0x00000001, "RTF_UP"),
+    (0x00000002, "RTF_GATEWAY"),
+    (0x00000004, "RTF_HOST"),
+    (0x00000008, "RTF_REINSTATE"),
+    (0x00000010, "RTF_DYNAMIC"),
+    (0x00000020, "RTF_MODIFIED"),
+    (0x00000200, "RTF_REJECT"),
+    (0x00010000, "RTF_DEFAULT"),
+    (0x00020000, "RTF_ALLONLINK"),
+    (0x00040000, "RTF_ADDRCONF"),
+    (0x00080000, "RTF_PREFIX_RT"),
+    (0x00100000, "RTF_ANYCAST"),
+    (0x00200000, "RTF_NONEXTHOP"),
+    (0x00400000, "RTF_EXPIRES"),
+    (0x00800000, "RTF_ROUTEINFO"),
+    (0x01000000, "RTF_CACHE"),
+    (0x02000000, "RTF_FLOW"),
+    (0x04000000, "RTF_POLICY"),
+    (0x80000000, "RTF_LOCAL"),
+]
+
+
+def parse_ip6_mr_cache(p: Path) -> List[Dict[str, Any]]:
+    # Group Origin Iif Pkts Bytes Wrong Oifs (addresses in full %pI6 form)
+    entries: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 6 or parts[0] == "Group":
+            continue
+        try:
+            group = socket.inet_ntop(socket.AF_INET6, socket.inet_pton(socket.AF_INET6, parts[0]))
+            origin = socket.inet_ntop(socket.AF_INET6, socket.inet_pton(socket.AF_INET6, parts[1]))
+        except (ValueError, OSError):
+            continue
+        entries.append({
+            "group": group,
+            "origin": origin,
+            # 65535: unresolved entry still waiting for the routing daemon
+            "iif": to_int(parts[2]),
+            "pkts": to_int(parts[3]),
+            "bytes": to_int(parts[4]),
+            "wrong_if": to_int(parts[5]),
+            "oifs": parts[6:],
+        })
+    return entries
+
+
+def parse_ip6_mr_vif(p: Path) -> List[Dict[str, Any]]:
+    # Interface BytesIn PktsIn BytesOut PktsOut Flags; rows start with the VIF index
+    vifs: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 6 or not parts[0].isdigit():
+            continue
+        vifs.append({
+            "idx": int(parts[0]),
+            "iface": parts[1],
+            "bytes_in": to_int(parts[2]),
+            "pkts_in": to_int(parts[3]),
+            "bytes_out": to_int(parts[4]),
+            "pkts_out": to_int(parts[5]),
+        })
+    return vifs
+
+
+def parse_ipv6_routes(p: Path) -> List[Dict[str, Any]]:
+    # dst dst_len src src_len next_hop metric refcnt use flags iface (all numbers hex)
+    routes: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 10:
+            continue
+        try:
+            nums = [int(parts[i], 16) for i in (1, 3, 5, 6, 7, 8)]
+        except ValueError:
+            continue
+        routes.append({
+            "destination": raw_ipv6(parts[0]),
+            "dest_prefix_len": nums[0],
+            "source": raw_ipv6(parts[2]),
+            "src_prefix_len": nums[1],
+            "next_hop": raw_ipv6(parts[4]),
+            "metric": nums[2],
+            "refcnt": nums[3],
+            "use": nums[4],
+            "flags": parts[8],
+            "flag_strings": [name for bit, name in RTF_FLAGS if nums[5] & bit],
+            "iface": parts[9],
+        })
+    return routes
+
+
+ROUTE_TYPES = ("unicast", "local", "broadcast", "anycast", "multicast", "blackhole", "unreachable", "prohibit", "throw", "nat")
+
+
+def ipv6_routes_by_type(ip_out: Path, routes: List[Dict[str, Any]]) -> Dict[str, int]:
+    # "ip -6 route show table all" prefixes every non-unicast route with its type.
+    # Without it only RTF_* flags are left: all reject types collapse into RTF_REJECT.
+    counts = {t: 0 for t in ROUTE_TYPES[:8]}
+    lines = [ln.split() for ln in read_text(ip_out).splitlines() if ln and not ln[0].isspace()]
+    if lines:
+        for parts in lines:
+            t = parts[0] if parts[0] in ROUTE_TYPES else "unicast"
+            counts[t] = counts.get(t, 0) + 1
+        return counts
+    for r in routes:
+        f = r["flag_strings"]
+        if "RTF_LOCAL" in f:
+            t = "local"
+        elif "RTF_ANYCAST" in f:
+            t = "anycast"
+        elif "RTF_REJECT" in f:
+            t = "unreachable"
+        elif r["destination"].startswith("ff"):
+            t = "multicast"
+        else:
+            t = "unicast"
+        counts[t] += 1
+    return counts
+
+
+IFA_F_DEPRECATED = 0x20
+
+
+def parse_if_inet6(p: Path) -> List[Dict[str, Any]]:
+    # addr ifindex prefix_len scope flags iface (numbers hex; flags = IFA_F_* low byte)
+    addrs: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 6:
+            continue
+        try:
+            ifindex, plen, scope, flags = (int(x, 16) for x in parts[1:5])
+        except ValueError:
+            continue
+        addrs.append({
+            "addr": raw_ipv6(parts[0]),
+            "ifindex": ifindex,
+            "prefix_len": plen,
+            "scope": scope,
+            "flags": flags,
+            "deprecated": bool(flags & IFA_F_DEPRECATED),
+            "iface": parts[5],
+        })
+    return addrs
+
+
+def default_gateway_v4(p: Path) -> str:
+    # Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+    best = ""
+    best_metric = -1
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 8 or parts[0] == "Iface":
+            continue
+        if parts[1] != "00000000" or parts[7] != "00000000":
+            continue
+        try:
+            metric = int(parts[6])
+        except ValueError:
+            continue
+        if best_metric < 0 or metric < best_metric:
+            best, best_metric = hex_addr(parts[2]), metric
+    return best
+
+
+def default_gateway_v6(p: Path) -> str:
+    # dst dst_len src src_len next_hop metric refcnt use flags iface
+    best = ""
+    best_metric = -1
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 10 or parts[0] != "0" * 32 or parts[1] != "00":
+            continue
+        if parts[4] == "0" * 32:
+            continue
+        try:
+            metric = int(parts[5], 16)
+        except ValueError:
+            continue
+        if best_metric < 0 or metric < best_metric:
+            best, best_metric = raw_ipv6(parts[4]), metric
+    return best
+
+
+def parse_softnet_stat(p: Path) -> Dict[str, Dict[str, int]]:
+    # one hex row per online CPU: processed dropped time_squeeze 0 0 0 0 0
+    # cpu_collision received_rps [flow_limit_count (3.11+)] [backlog_len cpu_id (5.10+)].
+    # 2.6.36/3.4 firmware prints 10 columns; missing counters are -1.
+    stats: Dict[str, Dict[str, int]] = {}
+    for idx, line in enumerate(read_text(p).splitlines()):
+        try:
+            cols = [int(x, 16) for x in line.split()]
+        except ValueError:
+            continue
+        if len(cols) < 3:
+            continue
+        cpu = cols[12] if len(cols) > 12 else idx
+        stats[f"cpu{cpu}"] = {
+            "processed": cols[0],
+            "dropped": cols[1],
+            "time_squeeze": cols[2],
+            "received_rps": cols[9] if len(cols) > 9 else -1,
+            "flow_limit_count": cols[10] if len(cols) > 10 else -1,
+        }
+    return stats
+
+
+def parse_kv_counters(p: Path) -> Dict[str, int]:
+    # "Name value" per line (/proc/net/snmp6, /proc/net/dev_snmp6/<iface>)
+    vals: Dict[str, int] = {}
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) != 2:
+            continue
+        try:
+            vals[parts[0]] = int(parts[1])
+        except ValueError:
+            continue
+    return vals
+
+
+IFACE_SNMP6_FIELDS = {
+    "Ip6InReceives": "in_receives",
+    "Ip6InDelivers": "in_delivers",
+    "Ip6InDiscards": "in_discards",
+    "Ip6OutRequests": "out_requests",
+    "Ip6OutForwDatagrams": "out_forw_datagrams",
+}
+
+
+def parse_dev_snmp6_typed(d: Path) -> Tuple[Dict[str, Dict[str, int]], Dict[str, Dict[str, int]]]:
+    # Fixed-key subset (always present, -1 if missing) plus the full raw map.
+    typed: Dict[str, Dict[str, int]] = {}
+    raw: Dict[str, Dict[str, int]] = {}
+    if not d.is_dir():
+        return typed, raw
+    for p in sorted(d.iterdir()):
+        if not p.is_file():
+            continue
+        vals = parse_kv_counters(p)
+        raw[p.name] = vals
+        typed[p.name] = {k: vals.get(name, -1) for name, k in IFACE_SNMP6_FIELDS.items()}
+    return typed, raw
+
+
+def parse_wireless(p: Path) -> List[Dict[str, Any]]:
+    # two header lines, then "iface: status link level noise nwid crypt frag retry misc beacon"
+    ifaces: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines()[2:]:
+        name, sep, rest = line.partition(":")
+        v = rest.replace(".", " ").split()
+        if not sep or len(v) < 4:
+            continue
+        wl = {
+            "iface": name.strip(),
+            "link_quality": to_int(v[1]),
+            "signal_level": to_int(v[2]),
+            "noise_level": to_int(v[3]),
+            # "Discarded retry": frames dropped after the MAC retry limit
+            "tx_failed": to_int(v[7]) if len(v) > 7 else -1,
+            # some vendor drivers append a raw retry counter after "beacon"
+            "tx_retry": to_int(v[10]) if len(v) > 10 else -1,
+        }
+        failed, retry = max(wl["tx_failed"], 0), max(wl["tx_retry"], 0)
+        wl["tx_quality"] = round(1.0 - failed / (failed + retry + 1), 4)
+        ifaces.append(wl)
+    return ifaces
+
+
+def freq_to_channel(mhz: int) -> int:
+    if mhz == 2484:
+        return 14
+    if 2412 <= mhz < 2484:
+        return (mhz - 2407) // 5
+    if 5955 <= mhz <= 7115:
+        return (mhz - 5950) // 5
+    # 4.9 GHz public safety band: channels 182-196 (cfg80211 uses 4000 MHz as base)
+    if 4910 <= mhz <= 4980:
+        return (mhz - 4000) // 5
+    if 5000 <= mhz <= 5895:
+        return (mhz - 5000) // 5
+    return 0
+
+
+def wireless_channels(net_dir: Path) -> Dict[str, int]:
+    # `iw dev`: "Interface wlan0" ... "channel 36 (5180 MHz), width: ..."
+    # iwconfig: "ra0  ... Channel=6" or "Frequency:2.437 GHz"
+    chans: Dict[str, int] = {}
+    iface = ""
+    for line in read_text(net_dir / "iw_dev.txt").splitlines():
+        t = line.split()
+        if len(t) >= 2 and t[0] == "Interface":
+            iface = t[1]
+        elif len(t) >= 2 and t[0] == "channel" and iface:
+            chans[iface] = to_int(t[1], 0)
+    for line in read_text(net_dir / "iwconfig.txt").splitlines():
+        if line and not line[0].isspace():
+            iface = line.split()[0]
+        if not iface or iface in chans:
+            continue
+        m = re.search(r"Channel[=:](\d+)", line)
+        if m:
+            chans[iface] = int(m.group(1))
+            continue
+        # a strict number: "[\d.]+" let "." or an endless digit run crash float()/round()
+        m = re.search(r"Frequency[=:](\d{1,3}(?:\.\d+)?) GHz", line)
+        if m:
+            chans[iface] = freq_to_channel(int(round(float(m.group(1)) * 1000)))
+    return chans
+
+
+def parse_conntrack(p: Path) -> List[Dict[str, Any]]:
+    # nf_conntrack: "ipv4 2 tcp 6 431999 ESTABLISHED src=.. dst=.. sport=.. dport=.. src=.. ... [ASSURED] mark=0 use=2"
+    # ip_conntrack (older kernels) has the same layout without the leading "ipv4 2".
+    entries: List[Dict[str, Any]] = []
+    for line in read_text(p, max_bytes=32_000_000).splitlines():
+        parts = line.split()
+        if parts and parts[0] in ("ipv4", "ipv6"):
+            family, parts = parts[0], parts[2:]
+        else:
+            family = "ipv4"
+        if len(parts) < 4:
+            continue
+        e: Dict[str, Any] = {
+            "family": family,
+            "proto": parts[0],
+            "protonum": to_int(parts[1]),
+            "timeout": to_int(parts[2]),
+            "state": "",
+            "orig": {},
+            "reply": {},
+            "flags": [],
+        }
+        tup = e["orig"]
+        for t in parts[3:]:
+            if t.startswith("["):
+                e["flags"].append(t.strip("[]"))
+                continue
+            k, sep, v = t.partition("=")
+            if not sep:
+                e["state"] = t
+            elif k in ("src", "dst", "sport", "dport", "type", "code", "id", "srckey", "dstkey", "spi", "packets", "bytes"):
+                if k in tup and tup is e["orig"]:
+                    tup = e["reply"]
+                tup[k] = v
+            else:
+                # gre entries carry "timeout=30, stream_timeout=180"; keep the numeric timeout
+                e.setdefault(k, v.rstrip(","))
+        entries.append(e)
+    return entries
+
+
+def ct_endpoint(tup: Dict[str, str], addr: str, port: str) -> str:
+    a = tup.get(addr, "")
+    if ":" in a:
+        a = f"[{a}]"
+    return f"{a}:{tup[port]}" if port in tup else a
+
+
+def extract_nat_mappings(entries: List[Dict[str, Any]]) -> List[Dict[str, str]]:
+    # Without NAT the reply tuple mirrors the original one; any difference
+    # means SNAT/masquerade (reply dst changed) or DNAT (reply src changed).
+    mappings: List[Dict[str, str]] = []
+    for e in entries:
+        o, r = e["orig"], e["reply"]
+        if not r:
+            continue
+        if o.get("src") == r.get("dst") and o.get("dst") == r.get("src") \
+                and o.get("sport") == r.get("dport") and o.get("dport") == r.get("sport"):
+            continue
+        mappings.append({
+            "proto": e["proto"],
+            "orig_src": ct_endpoint(o, "src", "sport"),
+            "orig_dst": ct_endpoint(o, "dst", "dport"),
+            "nat_src": ct_endpoint(r, "dst", "dport"),
+            "nat_dst": ct_endpoint(r, "src", "sport"),
+        })
+    return mappings
+
+
+def pptp_sessions(entries: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+    # GRE key fields hold the peer's PPTP call ID, so the original direction
+    # (client -> server) carries the server's call ID and the reply the client's.
+    # packets=/bytes= are only printed with net.netfilter.nf_conntrack_acct=1.
+    def call_id(tup: Dict[str, str]) -> int:
+        try:
+            return int(tup.get("dstkey", ""), 16)
+        except ValueError:
+            return -1
+
+    sessions: List[Dict[str, Any]] = []
+    for e in entries:
+        if e["proto"] != "gre":
+            continue
+        o, r = e["orig"], e["reply"]
+        sessions.append({
+            "server_call_id": call_id(o),
+            "client_call_id": call_id(r),
+            "client_ip": o.get("src", ""),
+            "server_ip": o.get("dst", ""),
+            "bytes_sent": to_int(o.get("bytes", "-1")),
+            "bytes_received": to_int(r.get("bytes", "-1")),
+        })
+    return sessions
+
+
+def ipsec_sas(entries: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+    # Upstream kernels track ESP as "unknown 50" (addresses only); spi= is
+    # printed by vendor ESP conntrack helpers. "" when the SPI is not known.
+    sas: List[Dict[str, Any]] = []
+    for e in entries:
+        if e["protonum"] != 50:
+            continue
+        o, r = e["orig"], e["reply"]
+        sas.append({
+            "spi": o.get("spi", ""),
+            "src_ip": o.get("src", ""),
+            "dst_ip": o.get("dst", ""),
+            "bytes_sent": to_int(o.get("bytes", "-1")),
+            "bytes_received": to_int(r.get("bytes", "-1")),
+        })
+    return sas
+
+
+def xfrm_sa_count(p: Path) -> int:
+    # "SAD count 4 Hash buckets 16 (max 524288)"; counts SAs of both directions
+    m = re.search(r"SAD count (\d+)", read_text(p))
+    return int(m.group(1)) if m else -1
+
+
+def tcp_idle_buckets(entries: List[Dict[str, Any]], established_timeout: int) -> Dict[str, int]:
+    # The timeout is re-armed on every packet, so timeout_established minus the
+    # remaining timeout is the time since the last packet (idle time), which
+    # equals the age only for connections that went silent after setup.
+    buckets = {"<1m": 0, "1m-5m": 0, "5m-1h": 0, ">1h": 0}
+    for e in entries:
+        if e["proto"] != "tcp" or e["state"] != "ESTABLISHED" or e["timeout"] < 0:
+            continue
+        idle = max(established_timeout - e["timeout"], 0)
+        if idle < 60:
+            buckets["<1m"] += 1
+        elif idle < 300:
+            buckets["1m-5m"] += 1
+        elif idle < 3600:
+            buckets["5m-1h"] += 1
+        else:
+            buckets[">1h"] += 1
+    return buckets
+
+
+def asymmetric_flows(entries: List[Dict[str, Any]], ratio: int = 100) -> List[str]:
+    # bytes= exists only with nf_conntrack_acct=1; a direction with 0 bytes
+    # counts as 1 so one-way flows (nothing came back) are reported too
+    flows: List[str] = []
+    for e in entries:
+        o, r = e["orig"], e["reply"]
+        ob, rb = to_int(o.get("bytes", "-1")), to_int(r.get("bytes", "-1"))
+        if ob < 0 or rb < 0:
+            continue
+        if max(ob, 1) / max(rb, 1) > ratio or max(rb, 1) / max(ob, 1) > ratio:
+            flows.append(f"{e['proto']} {ct_endpoint(o, 'src', 'sport')} -> {ct_endpoint(o, 'dst', 'dport')} "
+                         f"orig_bytes={ob} reply_bytes={rb}")
+    return flows
+
+
+def conntrack_mark_errors(entries: List[Dict[str, Any]], spec: str) -> List[str]:
+    # spec "LOW-HIGH", each bound decimal or 0x hex; one error per offending mark value
+    low_s, _, high_s = spec.partition("-")
+    try:
+        low, high = int(low_s, 0), int(high_s, 0)
+    except ValueError:
+        low, high = 1, 0
+    if low > high:
+        return [f"invalid --ct-mark-range {spec!r} (expected LOW-HIGH)"]
+    bad: Dict[int, int] = {}
+    for e in entries:
+        try:
+            mark = int(e.get("mark", "0"), 0)
+        except ValueError:
+            continue
+        if not low <= mark <= high:
+            bad[mark] = bad.get(mark, 0) + 1
+    return [f"mark {m:#x} on {n} entries is outside {low:#x}-{high:#x}" for m, n in sorted(bad.items())]
+
+
+def udp_expiry_buckets(entries: List[Dict[str, Any]]) -> Dict[str, int]:
+    # Bucketed by remaining timeout. Stream (ASSURED) UDP is re-armed to
+    # nf_conntrack_udp_timeout_stream (180 s) on every packet, so a flow that
+    # keeps sitting in the upper buckets is being refreshed (WireGuard keepalive).
+    buckets = {"<10s": 0, "10s-60s": 0, "1m-5m": 0, ">5m": 0}
+    for e in entries:
+        if e["proto"] != "udp" or e["timeout"] < 0:
+            continue
+        if e["timeout"] < 10:
+            buckets["<10s"] += 1
+        elif e["timeout"] < 60:
+            buckets["10s-60s"] += 1
+        elif e["timeout"] < 300:
+            buckets["1m-5m"] += 1
+        else:
+            buckets[">5m"] += 1
+    return buckets
+
+
+def conntrack_state_anomalies(entries: List[Dict[str, Any]], timeouts: Dict[str, int], limit: int = 100) -> List[str]:
+    # The kernel only ever lowers a TCP entry's timeout below the per-state
+    # sysctl (unacknowledged/max_retrans cases), so a remaining timeout above
+    # it, or an ESTABLISHED entry that never saw a reply, is inconsistent.
+    # A low ESTABLISHED timeout is not flagged: unacknowledged data caps it at
+    # 300 s legitimately. Entries created before a sysctl was lowered will
+    # also show up until they are refreshed.
+    out: List[str] = []
+    for e in entries:
+        if e["proto"] != "tcp" or not e["state"]:
+            continue
+        o = e["orig"]
+        conn = f"{o.get('src', '')}:{o.get('sport', '')} -> {o.get('dst', '')}:{o.get('dport', '')}"
+        lim = timeouts.get(e["state"].lower(), -1)
+        if lim >= 0 and e["timeout"] > lim + 1:
+            out.append(f"tcp {e['state']} {conn}: timeout {e['timeout']}s exceeds nf_conntrack_tcp_timeout_{e['state'].lower()} ({lim}s)")
+        elif e["state"] == "ESTABLISHED" and "UNREPLIED" in e["flags"]:
+            out.append(f"tcp ESTABLISHED {conn}: no reply seen (mid-stream pickup?)")
+        if len(out) >= limit:
+            break
+    return out
+
+
+def parse_arp(p: Path) -> List[Dict[str, Any]]:
+    # IP address, HW type, Flags, HW address, Mask, Device
+    entries: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines()[1:]:
+        parts = line.split()
+        if len(parts) < 6:
+            continue
+        try:
+            flags = int(parts[2], 16)
+        except ValueError:
+            continue
+        entries.append({"ip": parts[0], "flags": flags, "hwaddr": parts[3], "iface": parts[5]})
+    return entries
+
+
+def arp_age_estimates(arp: List[Dict[str, Any]], stats: Path, reachable_ms: Dict[str, int]) -> List[Dict[str, Any]]:
+    # Heuristic: /proc/net/arp carries no timers. The age used here is the time
+    # since the neighbour was last confirmed reachable, from the "used" triple
+    # of `ip -s neigh`; it says nothing about when the entry was created.
+    # Reachable time is randomised to 0.5..1.5 x base_reachable_time, so an
+    # entry unconfirmed for more than 3 x base is flagged possibly stale.
+    confirmed: Dict[Tuple[str, str], int] = {}
+    for line in read_text(stats).splitlines():
+        parts = line.split()
+        m = re.search(r"used (\d+)/(\d+)/(\d+)", line)
+        if m and "dev" in parts[:-1]:
+            confirmed[(parts[0], parts[parts.index("dev") + 1])] = int(m.group(2))
+    estimates: List[Dict[str, Any]] = []
+    for a in arp:
+        age = confirmed.get((a["ip"], a["iface"]), -1)
+        base = reachable_ms.get(a["iface"], reachable_ms.get("default", 30000)) / 1000.0
+        estimates.append({
+            **a,
+            "estimated_age_sec": float(age),
+            # permanent entries (ATF_PERM) never expire
+            "possibly_stale": age >= 0 and not a["flags"] & 0x4 and age > 3 * base,
+        })
+    return estimates
+
+
+def arp_fingerprint(arp: List[Dict[str, Any]]) -> str:
+    # The kernel keeps no ARP generation counter (neither /proc nor an
+    # RTM_GETNEIGH dump carries one); a digest of the sorted table serves the
+    # same purpose: equal digests between two runs mean an unchanged table.
+    rows = sorted(f"{a['ip']} {a['hwaddr']} {a['flags']:#x} {a['iface']}" for a in arp)
+    return hashlib.sha256("\n".join(rows).encode("utf-8")).hexdigest()
+
+
+def arp_conflicts(arp: List[Dict[str, Any]]) -> Tuple[int, List[str]]:
+    # /proc/net/arp records no ARP opcode; conflicts show up as one IP learned
+    # with several MACs (possible only across interfaces) or as a broadcast MAC.
+    macs: Dict[str, set] = {}
+    bcast = 0
+    for a in arp:
+        mac = a["hwaddr"].lower()
+        if mac == "00:00:00:00:00:00":
+            continue
+        if mac == "ff:ff:ff:ff:ff:ff":
+            bcast += 1
+        macs.setdefault(a["ip"], set()).add(mac)
+    dups = sorted(ip for ip, m in macs.items() if len(m) > 1)
+    return len(dups) + bcast, dups
+
+
+def arp_stale_count(arp: List[Dict[str, Any]], conntrack: List[Dict[str, Any]], threshold: int) -> int:
+    # /proc/net/arp has no timestamps, so "stale" is approximated: a completed
+    # entry (flags 0x2) whose IP appears in no conntrack entry with at least
+    # `threshold` seconds of timeout left. Idle-but-valid neighbours (printers,
+    # IoT devices without traffic) also count, so treat it as a hint only.
+    active = set()
+    for e in conntrack:
+        if e["timeout"] < threshold:
+            continue
+        for tup in (e["orig"], e["reply"]):
+            active.update(v for k, v in tup.items() if k in ("src", "dst"))
+    return sum(1 for a in arp if a["flags"] & 0x2 and a["ip"] not in active)
+
+
+IP6_IN_DROP_FIELDS = ["Ip6InHdrErrors", "Ip6InAddrErrors", "Ip6InNoRoutes", "Ip6InUnknownProtos",
+                      "Ip6InTruncatedPkts", "Ip6InDiscards", "Ip6InTooBigErrors"]
+
+
+def snmp6_imbalance(raw: Dict[str, Dict[str, int]], min_packets: int = 1000) -> List[str]:
+    # Ip6OutForwDatagrams is booked on the egress interface, so it cannot be
+    # compared with the same interface's Ip6InReceives; instead report
+    # interfaces where more than 10% of received packets were dropped on input.
+    out: List[str] = []
+    for iface, vals in sorted(raw.items()):
+        rx = vals.get("Ip6InReceives", 0)
+        if rx < min_packets:
+            continue
+        dropped = sum(vals.get(k, 0) for k in IP6_IN_DROP_FIELDS)
+        if dropped * 10 > rx:
+            out.append(f"{iface}: {dropped} of {rx} received IPv6 packets dropped on input ({dropped * 100 // rx}%)")
+    return out
+
+
+def parse_pfkey(p: Path) -> Tuple[int, List[int]]:
+    # sk RefCnt Rmem Wmem User Inode ("User" is the owner uid; no pid is exposed)
+    count = 0
+    uids: List[int] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 6 or parts[0] == "sk":
+            continue
+        count += 1
+        uid = to_int(parts[4])
+        if uid >= 0 and uid not in uids:
+            uids.append(uid)
+    return count, sorted(uids)
+
+
+def ipx_socket_count(net: Path) -> int:
+    p = net / "ipx" / "socket"
+    if not p.is_file():
+        p = net / "ipx"
+    if not p.is_file():
+        return 0
+    # first line is the "Local_Address Remote_Address ..." header
+    return max(len([l for l in read_text(p).splitlines() if l.strip()]) - 1, 0)
+
+
+def parse_proc_netstat(p: Path) -> Dict[str, Dict[str, int]]:
+    # /proc/net/netstat: "TcpExt: Name1 Name2 ..." followed by "TcpExt: v1 v2 ..."
+    groups: Dict[str, Dict[str, int]] = {}
+    lines = read_text(p).splitlines()
+    for names, vals in zip(lines[0::2], lines[1::2]):
+        n = names.split()
+        v = vals.split()
+        if len(n) != len(v) or not n or n[0] != v[0]:
+            continue
+        groups[n[0].rstrip(":")] = {k: to_int(x) for k, x in zip(n[1:], v[1:])}
+    return groups
+
+
+def parse_cgroup_memory(p: Path) -> List[Dict[str, Any]]:
+    groups: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines():
+        if line.startswith("#"):
+            continue
+        parts = line.split("\t")
+        if len(parts) < 4:
+            continue
+        groups.append({
+            "cgroup": parts[0],
+            "limit_bytes": to_int(parts[1]),
+            "usage_bytes": to_int(parts[2]),
+            "oom_kill_count": to_int(parts[3]),
+        })
+    return groups
+
+
+def parse_tsv_counts(p: Path) -> Dict[str, int]:
+    # "# header" + "name<TAB>count" rows written by the shell collector
+    counts: Dict[str, int] = {}
+    for line in read_text(p).splitlines():
+        if not line or line.startswith("#"):
+            continue
+        name, _, val = line.partition("\t")
+        counts[name] = to_int(val.strip(), 0)
+    return counts
+
+
+def parse_pppoe(p: Path) -> List[Dict[str, Any]]:
+    # Id (hex session id) Address (peer MAC) Device
+    sessions: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 3 or parts[0] == "Id":
+            continue
+        try:
+            sid = int(parts[0], 16)
+        except ValueError:
+            continue
+        sessions.append({"session_id": sid, "peer_mac": parts[1], "dev": parts[2]})
+    return sessions
+
+
+RT6_STATS_FIELDS = ["fib_nodes", "fib_route_nodes", "fib_rt_alloc", "fib_rt_entries", "fib_rt_cache", "dst_entries", "fib_discarded_routes"]
+
+
+def parse_rt6_stats(p: Path) -> Dict[str, int]:
+    # single line of hex counters, order as in net/ipv6/route.c
+    try:
+        vals = [int(x, 16) for x in read_text(p).split()]
+    except ValueError:
+        return {}
+    return dict(zip(RT6_STATS_FIELDS, vals))
+
+
+def parse_net_dev(p: Path) -> List[Dict[str, Any]]:
+    # iface: 8 receive columns, then 8 transmit columns
+    ifaces: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines():
+        if ":" not in line:
+            continue
+        name, _, rest = line.partition(":")
+        cols = rest.split()
+        if len(cols) < 16:
+            continue
+        try:
+            v = [int(x) for x in cols[:16]]
+        except ValueError:
+            continue
+        ifaces.append(
+            {
+                "iface": name.strip(),
+                "rx_bytes": v[0],
+                "rx_packets": v[1],
+                "rx_errs": v[2],
+                "rx_drop": v[3],
+                "rx_multicast": v[7],
+                "tx_bytes": v[8],
+                "tx_packets": v[9],
+                "tx_errs": v[10],
+                "tx_drop": v[11],
+                "tx_collisions": v[13],
+            }
+        )
+    return ifaces
+
+
+def parse_dev_mcast(p: Path) -> List[Dict[str, Any]]:
+    # ifindex name refcount global_use hwaddr(hex)
+    groups: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 5:
+            continue
+        h = parts[4]
+        try:
+            groups.append(
+                {
+                    "idx": int(parts[0]),
+                    "iface": parts[1],
+                    "users": int(parts[2]),
+                    "dst_addr": ":".join(h[i:i + 2] for i in range(0, len(h), 2)),
+                }
+            )
+        except ValueError:
+            continue
+    return groups
+
+
+IP_PROTOS = {"1": "icmp", "2": "igmp", "6": "tcp", "17": "udp", "47": "gre", "50": "esp", "51": "ah", "58": "icmpv6", "132": "sctp"}
+
+
+def parse_conntrack_expect(p: Path) -> List[Dict[str, Any]]:
+    # "<timeout|-> l3proto = N proto=N src=.. dst=.. sport=.. dport=.. [flags] helper[/policy]"
+    expects: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if not parts:
+            continue
+        kv: Dict[str, str] = {}
+        words: List[str] = []
+        for t in parts[1:]:
+            k, eq, v = t.partition("=")
+            if eq and v:
+                kv.setdefault(k, v)
+            elif not eq and t not in ("l3proto", "PERMANENT", "INACTIVE", "USERSPACE") and not t.isdigit():
+                words.append(t)
+        try:
+            timeout_ms = int(parts[0]) * 1000
+        except ValueError:
+            timeout_ms = -1
+        proto = kv.get("proto", "")
+        expects.append(
+            {
+                "proto": IP_PROTOS.get(proto, proto),
+                "src_ip": kv.get("src", ""),
+                "src_port": kv.get("sport", ""),
+                "dst_ip": kv.get("dst", ""),
+                "dst_port": kv.get("dport", ""),
+                "master": words[-1] if words else "",
+                "timeout_ms": timeout_ms,
+            }
+        )
+    return expects
+
+
+def parse_protocols(p: Path) -> List[Dict[str, Any]]:
+    # protocol size sockets memory press maxhdr slab module <method flags...>
+    protos: List[Dict[str, Any]] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 8 or parts[0] == "protocol":
+            continue
+        try:
+            protos.append(
+                {
+                    "name": parts[0],
+                    "size": int(parts[1]),
+                    "sockets": int(parts[2]),
+                    "memory": int(parts[3]),
+                    "pressure": parts[4],
+                    "max_header": int(parts[5]),
+                    "slab": parts[6] == "yes",
+                    "module": parts[7],
+                }
+            )
+        except ValueError:
+            continue
+    return protos
+
+
+def parse_stat_table(p: Path) -> List[Dict[str, int]]:
+    # /proc/net/stat/*: header row of names, then one row of hex counters per CPU
+    rows: List[Dict[str, int]] = []
+    lines = read_text(p).splitlines()
+    if not lines:
+        return rows
+    names = lines[0].split()
+    for line in lines[1:]:
+        try:
+            vals = [int(x, 16) for x in line.split()]
+        except ValueError:
+            continue
+        rows.append(dict(zip(names, vals)))
+    return rows
+
+
+def sum_stat_table(p: Path) -> Dict[str, int]:
+    # Counters are per CPU; "entries" is a global gauge repeated on every row.
+    rows = parse_stat_table(p)
+    total: Dict[str, int] = {}
+    for row in rows:
+        for k, v in row.items():
+            total[k] = total.get(k, 0) + v
+    if rows and "entries" in rows[0]:
+        total["entries"] = rows[0]["entries"]
+    return total
+
+
+def route_cache_entries(net: Path) -> int:
+    # Kernels <= 3.5 list the cache in /proc/net/rt_cache (header + one line per entry).
+    # Newer kernels keep only the header there; use "entries" from /proc/net/stat/rt_cache.
+    lines = [l for l in read_text(net / "rt_cache", max_bytes=8_000_000).splitlines() if l.strip()]
+    if len(lines) > 1:
+        return len(lines) - 1
+    stat = parse_stat_table(net / "stat" / "rt_cache")
+    if stat:
+        return stat[0].get("entries", 0)
+    return -1
+
+
+def parse_netns(p: Path) -> Tuple[int, List[int]]:
+    # rows: pid<TAB>net:[inode]; the "self" row is the collector's namespace
+    own = 0
+    seen: List[int] = []
+    for line in read_text(p, max_bytes=2_000_000).splitlines():
+        if not line or line.startswith("#"):
+            continue
+        pid, _, ns = line.partition("\t")
+        m = re.search(r"\[(\d+)\]", ns)
+        if not m:
+            continue
+        ino = int(m.group(1))
+        if pid == "self":
+            own = ino
+        elif ino not in seen:
+            seen.append(ino)
+    return own, sorted(i for i in seen if i != own)
+
+
+def to_int(s: str, default: int = -1) -> int:
+    try:
+        return int(s)
+    except ValueError:
+        return default
+
+
+def parse_bond(p: Path) -> Dict[str, Any]:
+    # "Key: value" lines; "Slave Interface:" opens a slave section and
+    # "details actor/partner lacp pdu:" opens an indented LACP sub-block.
+    bond: Dict[str, Any] = {"name": p.name, "mode": "", "mii_status": "", "active_slave": "", "slaves": []}
+    slave: Dict[str, Any] = {}
+    pdu = ""
+    for raw in read_text(p).splitlines():
+        line = raw.strip()
+        if line.endswith("lacp pdu:"):
+            pdu = "actor" if "actor" in line else "partner"
+            continue
+        if not raw.startswith(" "):
+            pdu = ""
+        key, sep, val = line.partition(":")
+        if not sep:
+            continue
+        key, val = key.strip(), val.strip()
+        if key == "Slave Interface":
+            slave = {"name": val, "mii_status": "", "aggregator_id": -1, "actor_key": -1}
+            bond["slaves"].append(slave)
+        elif slave:
+            if key == "MII Status":
+                slave["mii_status"] = val
+            elif key == "Aggregator ID":
+                slave["aggregator_id"] = to_int(val)
+            elif key == "port key" and pdu == "actor":
+                slave["actor_key"] = to_int(val)
+        elif key == "Bonding Mode":
+            bond["mode"] = val
+        elif key == "MII Status":
+            bond["mii_status"] = val
+        elif key == "Currently Active Slave":
+            # active-backup/tlb/alb only; "None" while no slave is up
+            bond["active_slave"] = "" if val == "None" else val
+        elif key == "LACP rate":
+            bond["lacp_rate"] = val
+        elif key == "Actor Key":
+            bond["ad_actor_key"] = to_int(val)
+        elif key == "Partner Key":
+            bond["ad_partner_key"] = to_int(val)
+        elif key == "Partner Mac Address":
+            bond["ad_partner_mac"] = val
+    return bond
+
+
+def parse_ip_neigh(p: Path) -> List[Dict[str, str]]:
+    # "ADDR dev IFACE [lladdr MAC] [router] STATE" (output of `ip neigh`)
+    entries: List[Dict[str, str]] = []
+    for line in read_text(p).splitlines():
+        parts = line.split()
+        if len(parts) < 3:
+            continue
+        e = {"addr": parts[0], "iface": "", "lladdr": "", "state": parts[-1]}
+        for i, t in enumerate(parts[:-1]):
+            if t == "dev":
+                e["iface"] = parts[i + 1]
+            elif t == "lladdr":
+                e["lladdr"] = parts[i + 1]
+        entries.append(e)
+    return entries
+
+
+def parse_ip_addr(p: Path) -> List[Dict[str, Any]]:
+    # `ip addr` output; iproute2/busybox ip query addresses over netlink.
+    addrs: List[Dict[str, Any]] = []
+    iface = ""
+    for line in read_text(p).splitlines():
+        m = re.match(r"\d+:\s+([^:@\s]+)", line)
+        if m:
+            iface = m.group(1)
+            continue
+        parts = line.split()
+        if len(parts) < 2 or parts[0] not in ("inet", "inet6") or not iface:
+            continue
+        addr, _, plen = parts[1].partition("/")
+        scope = parts[parts.index("scope") + 1] if "scope" in parts[:-1] else ""
+        addrs.append({"iface": iface, "addr": addr, "prefix_len": to_int(plen), "scope": scope, "family": parts[0]})
+    addrs.sort(key=lambda a: (a["iface"], a["family"], a["addr"]))
+    return addrs
+
+
+def read_sysctls(d: Path, names: List[str]) -> Dict[str, str]:
+    vals: Dict[str, str] = {}
+    for name in names:
+        p = d / name
+        if p.is_file():
+            vals[name] = read_text(p, max_bytes=4096).strip()
+    return vals
+
+
+def netfilter_rule_counts(net: Path, iptables_save: Path) -> Dict[str, int]:
+    # Tables come from /proc/net/ip_tables_names (loaded tables, possibly empty).
+    # Rule counts come from the iptables-save snapshot: the kernel only exposes
+    # rules through getsockopt(IPT_SO_GET_ENTRIES), not as readable /proc text.
+    counts: Dict[str, int] = {}
+    for name in read_text(net / "ip_tables_names").split():
+        counts[name] = 0
+    table = ""
+    for line in read_text(iptables_save, max_bytes=8_000_000).splitlines():
+        if line.startswith("*"):
+            table = line[1:].strip()
+            counts.setdefault(table, 0)
+        elif line.startswith("-A ") and table:
+            counts[table] += 1
+    return counts
+
+
+def netfilter_chain_counts(iptables_save: Path, nft_ruleset: Path) -> Dict[str, Dict[str, int]]:
+    # table -> chain -> rule count. Tables without a legacy iptables snapshot
+    # (nftables-only kernels) fall back to "nft list ruleset", keyed as
+    # "<family> <table>" since nft table names are only unique per family.
+    counts: Dict[str, Dict[str, int]] = {}
+    table = ""
+    for line in read_text(iptables_save, max_bytes=8_000_000).splitlines():
+        if line.startswith("*"):
+            table = line[1:].strip()
+            counts.setdefault(table, {})
+        elif line.startswith(":") and table:
+            counts[table].setdefault(line[1:].split()[0], 0)
+        elif line.startswith("-A ") and table:
+            parts = line.split()
+            if len(parts) >= 2:
+                counts[table][parts[1]] = counts[table].get(parts[1], 0) + 1
+    if counts:
+        return counts
+
+    table = chain = ""
+    depth = 0
+    for raw in read_text(nft_ruleset, max_bytes=8_000_000).splitlines():
+        line = raw.strip()
+        m = re.match(r"table (\S+) (\S+) \{", line)
+        if m and depth == 0:
+            table = f"{m.group(1)} {m.group(2)}"
+            counts.setdefault(table, {})
+        m = re.match(r"chain (\S+) \{", line)
+        if m and depth == 1 and table:
+            chain = m.group(1)
+            counts[table].setdefault(chain, 0)
+        elif depth == 2 and chain and line and not line.startswith(("type ", "policy ", "comment ", "}")):
+            counts[table][chain] += 1
+        depth += line.count("{") - line.count("}")
+        if depth < 2:
+            chain = ""
+        if depth < 1:
+            table = ""
+            depth = 0
+    return counts
+
+
+def driver_info(d: Path, budget: int = 256 * 1024) -> Dict[str, str]:
+    info: Dict[str, str] = {}
+    if not d.is_dir():
+        return info
+    for p in sorted(d.iterdir()):
+        if budget <= 0:
+            break
+        if not p.is_file():
+            continue
+        txt = read_text(p, max_bytes=budget)
+        budget -= len(txt.encode("utf-8", errors="replace"))
+        info[p.name] = txt
+    return info
+
+
+def loaded_modules(p: Path) -> List[str]:
+    return [line.split()[0] for line in read_text(p).splitlines() if line.strip()]
+
+
+def ebtables_tables(net: Path, modules: List[str]) -> Tuple[bool, List[str]]:
+    for rel in ("ebtables", "bridge/ebtables"):
+        if (net / rel).is_file():
+            return True, sorted(set(read_text(net / rel).split()))
+    tables = sorted(m[len("ebtable_"):] for m in modules if m.startswith("ebtable_"))
+    return bool(tables) or "ebtables" in modules, tables
+
+
+# modules whose extension names differ from the module suffix
+XT_MODULE_EXTENSIONS = {
+    "xt_tcpudp": ["tcp", "udp"],
+    "xt_nat": ["snat", "dnat"],
+    "ip6t_hbh": ["hbh", "dst"],
+    "ip6t_npt": ["snpt", "dnpt"],
+}
+# xt_ modules that register for NFPROTO_IPV4 only and never show up in ip6_tables_*
+XT_IPV4_ONLY = {"xt_realm", "xt_osf"}
+
+
+def unmatched_netfilter_modules(modules: List[str], matches: List[str], targets: List[str]) -> List[str]:
+    # A loaded xt_*/ip6t_* module registers its extensions from module_init;
+    # when none of them is listed the init failed half-way and rules using it
+    # cannot be inserted. Targets are checked too: xt_ covers both kinds.
+    known = {n.lower() for n in matches + targets}
+    unmatched: List[str] = []
+    for m in modules:
+        if m.lower() in XT_IPV4_ONLY:
+            continue
+        for prefix in ("xt_", "ip6t_"):
+            if not m.startswith(prefix):
+                continue
+            # module names keep the target case (ip6t_NPT, xt_CT)
+            names = XT_MODULE_EXTENSIONS.get(m.lower(), [m[len(prefix):].lower()])
+            if not known.intersection(names):
+                unmatched.append(m)
+    return sorted(unmatched)
+
+
+def conntrack_capacity(sysctl: Path) -> Dict[str, Any]:
+    # nf_conntrack_* on current kernels, ip_conntrack_* on old 2.6 firmware
+    cur = read_sysctls(sysctl / "net" / "netfilter", ["nf_conntrack_count", "nf_conntrack_max"])
+    if not cur:
+        old = read_sysctls(sysctl / "net" / "ipv4" / "netfilter", ["ip_conntrack_count", "ip_conntrack_max"])
+        cur = {k.replace("ip_", "nf_", 1): v for k, v in old.items()}
+    count = to_int(cur.get("nf_conntrack_count", ""))
+    mx = to_int(cur.get("nf_conntrack_max", ""))
+    used = round(count * 100.0 / mx, 1) if count >= 0 and mx > 0 else -1.0
+    return {"current": count, "max": mx, "used_pct": used}
+
+
+def influx_escape(s: str) -> str:
+    return s.replace("\\", "\\\\").replace(",", "\\,").replace("=", "\\=").replace(" ", "\\ ")
+
+
+def influx_line(measurement: str, tags: Dict[str, str], fields: Dict[str, Any]) -> str:
+    # numeric fields only: ints get the "i" suffix, bools are written as true/false.
+    # -1 / -1.0 is the "not available" sentinel and is left out, so dashboards
+    # see a gap instead of a fake sample (other negative rates are real data).
+    fs = []
+    for k, v in fields.items():
+        if isinstance(v, bool):
+            fs.append(f"{influx_escape(k)}={'true' if v else 'false'}")
+        elif isinstance(v, int) and v != -1:
+            fs.append(f"{influx_escape(k)}={v}i")
+        elif isinstance(v, float) and v != -1.0:
+            fs.append(f"{influx_escape(k)}={v!r}")
+    if not fs:
+        return ""
+    ts = "".join(f",{influx_escape(k)}={influx_escape(v)}" for k, v in sorted(tags.items()) if v)
+    return f"{measurement}{ts} {','.join(fs)}"
+
+
+def influx_lines(out: Dict[str, Any], w: Path, extra_tags: List[str]) -> List[str]:
+    tags = {"hostname": read_text(w / "meta" / "hostname.txt").strip() or socket.gethostname()}
+    for t in extra_tags:
+        k, sep, v = t.partition("=")
+        if sep and k:
+            tags[k] = v
+    lines = []
+    for iface in out.get("net_ifaces", []):
+        lines.append(influx_line("maxprobe_net", {**tags, "iface": iface["iface"]}, iface))
+    mem: Dict[str, Any] = {}
+    for line in read_text(w / "sys" / "proc" / "meminfo").splitlines():
+        k, _, v = line.partition(":")
+        if v.split() and v.split()[0].isdigit():
+            mem[k.strip()] = int(v.split()[0])
+    lines.append(influx_line("maxprobe_mem", tags, mem))
+    ports: Dict[Tuple[str, str], int] = {}
+    for line in read_text(w / "net" / "listen_ports.tsv").splitlines():
+        parts = line.split("\t")
+        if line.startswith("#") or len(parts) < 3:
+            continue
+        ports[(parts[0], parts[2])] = ports.get((parts[0], parts[2]), 0) + 1
+    for (proto, port), n in sorted(ports.items()):
+        lines.append(influx_line("maxprobe_listen", {**tags, "proto": proto, "port": port}, {"sockets": n}))
+    # every other numeric value of the inventory: top-level scalars and one level of nested dicts
+    probe: Dict[str, Any] = {}
+    for k, v in out.items():
+        if isinstance(v, (bool, int, float)):
+            probe[k] = v
+        elif isinstance(v, dict):
+            for sk, sv in v.items():
+                if isinstance(sv, (bool, int, float)):
+                    probe[f"{k}_{sk}"] = sv
+    lines.append(influx_line("maxprobe_probe", tags, probe))
+    return [l for l in lines if l]
+
+
+def msgpack_encode(v: Any) -> bytes:
+    # Minimal MessagePack encoder for the JSON-shaped probe output (None, bool,
+    # int, float, str, list, dict); the msgpack package isn't in Entware's python3.
+    # Ints outside the 64-bit range fall back to float64, like most decoders would.
+    pack = struct.pack
+    if v is None:
+        return b"\xc0"
+    if isinstance(v, bool):
+        return b"\xc3" if v else b"\xc2"
+    if isinstance(v, int) and -(1 << 63) <= v < (1 << 64):
+        if 0 <= v < 0x80:
+            return pack(">B", v)
+        if -32 <= v < 0:
+            return pack(">b", v)
+        if v >= 0:
+            for code, fmt, limit in ((0xcc, ">B", 1 << 8), (0xcd, ">H", 1 << 16), (0xce, ">I", 1 << 32)):
+                if v < limit:
+                    return pack(">B", code) + pack(fmt, v)
+            return b"\xcf" + pack(">Q", v)
+        for code, fmt, limit in ((0xd0, ">b", 1 << 7), (0xd1, ">h", 1 << 15), (0xd2, ">i", 1 << 31)):
+            if v >= -limit:
+                return pack(">B", code) + pack(fmt, v)
+        return b"\xd3" + pack(">q", v)
+    if isinstance(v, (int, float)):
+        return b"\xcb" + pack(">d", float(v))
+    if isinstance(v, str):
+        b = v.encode("utf-8")
+        n = len(b)
+        if n < 32:
+            return pack(">B", 0xa0 | n) + b
+        head = pack(">BB", 0xd9, n) if n < 1 << 8 else pack(">BH", 0xda, n) if n < 1 << 16 else pack(">BI", 0xdb, n)
+        return head + b
+    if isinstance(v, (list, tuple)):
+        n = len(v)
+        head = pack(">B", 0x90 | n) if n < 16 else pack(">BH", 0xdc, n) if n < 1 << 16 else pack(">BI", 0xdd, n)
+        return head + b"".join(msgpack_encode(x) for x in v)
+    if isinstance(v, dict):
+        n = len(v)
+        head = pack(">B", 0x80 | n) if n < 16 else pack(">BH", 0xde, n) if n < 1 << 16 else pack(">BI", 0xdf, n)
+        return head + b"".join(msgpack_encode(str(k)) + msgpack_encode(x) for k, x in v.items())
+    return msgpack_encode(str(v))
+
+
+def cbor_head(major: int, n: int) -> bytes:
+    if n < 24:
+        return struct.pack(">B", major << 5 | n)
+    for ai, fmt, limit in ((24, ">B", 1 << 8), (25, ">H", 1 << 16), (26, ">I", 1 << 32)):
+        if n < limit:
+            return struct.pack(">B", major << 5 | ai) + struct.pack(fmt, n)
+    return struct.pack(">BQ", major << 5 | 27, n)
+
+
+def cbor_encode(v: Any) -> bytes:
+    # Canonical CBOR (RFC 8949 4.2.1 core deterministic encoding): shortest
+    # heads, floats in the shortest of half/single/double that keeps the value,
+    # map keys ordered by their encoded bytes. Same output for the same data,
+    # so snapshots can be hashed or diffed byte for byte.
+    if v is None:
+        return b"\xf6"
+    if isinstance(v, bool):
+        return b"\xf5" if v else b"\xf4"
+    if isinstance(v, int) and -(1 << 64) <= v < (1 << 64):
+        return cbor_head(0, v) if v >= 0 else cbor_head(1, -1 - v)
+    if isinstance(v, (int, float)):
+        f = float(v)
+        if f != f:
+            return b"\xf9\x7e\x00"
+        for code, fmt in ((0xf9, ">e"), (0xfa, ">f")):
+            try:
+                b = struct.pack(fmt, f)
+            except (OverflowError, struct.error):
+                continue
+            if struct.unpack(fmt, b)[0] == f:
+                return struct.pack(">B", code) + b
+        return b"\xfb" + struct.pack(">d", f)
+    if isinstance(v, str):
+        b = v.encode("utf-8")
+        return cbor_head(3, len(b)) + b
+    if isinstance(v, (list, tuple)):
+        return cbor_head(4, len(v)) + b"".join(cbor_encode(x) for x in v)
+    if isinstance(v, dict):
+        items = sorted((cbor_encode(str(k)), cbor_encode(x)) for k, x in v.items())
+        return cbor_head(5, len(items)) + b"".join(k + x for k, x in items)
+    return cbor_encode(str(v))
+
+
+def parse_duration(s: str) -> int:
+    # "3600", "90s", "30m", "12h", "30d", "2w" -> seconds; -1 when malformed
+    m = re.fullmatch(r"(\d+)([smhdw]?)", s.strip())
+    if not m:
+        return -1
+    return int(m.group(1)) * {"": 1, "s": 1, "m": 60, "h": 3600, "d": 86400, "w": 604800}[m.group(2)]
+
+
+def store_snapshot(db: Path, ts_utc: str, hostname: str, payload: str, prune: str) -> str:
+    # Appends the run to snapshots(id, ts_utc, hostname, payload) and, with
+    # prune, deletes rows older than that age. sqlite3 is a separate Entware
+    # package (python3-sqlite3), so its absence is reported, not fatal.
+    # Returns an error message, "" on success.
+    try:
+        import sqlite3
+    except ImportError:
+        return f"--db {db}: python3 sqlite3 module missing (opkg install python3-sqlite3); snapshot not stored"
+    keep = parse_duration(prune) if prune else 0
+    if keep < 0:
+        return f"--db-prune {prune!r}: expected N[s|m|h|d|w]; snapshot not stored"
+    try:
+        con = sqlite3.connect(str(db))
+        try:
+            with con:
+                con.execute("CREATE TABLE IF NOT EXISTS snapshots ("
+                            "id INTEGER PRIMARY KEY, ts_utc TEXT NOT NULL, hostname TEXT, payload TEXT NOT NULL)")
+                con.execute("INSERT INTO snapshots (ts_utc, hostname, payload) VALUES (?, ?, ?)",
+                            (ts_utc, hostname, payload))
+                if keep > 0:
+                    # ts_utc is ISO-8601 UTC, so text comparison orders by time
+                    cutoff = (datetime.strptime(ts_utc, "%Y-%m-%dT%H:%M:%SZ")
+                              - timedelta(seconds=keep)).strftime("%Y-%m-%dT%H:%M:%SZ")
+                    con.execute("DELETE FROM snapshots WHERE ts_utc < ?", (cutoff,))
+        finally:
+            con.close()
+    except sqlite3.Error as e:
+        return f"--db {db}: {e}; snapshot not stored"
+    return ""
+
+
 def main() -> int:
     ap = argparse.ArgumentParser()
     ap.add_argument("--workdir", required=True)
+    ap.add_argument("--collect-established", action="store_true")
+    ap.add_argument("--established-max", type=int, default=5000)
+    ap.add_argument("--resolve-raw-pids", action="store_true")
+    ap.add_argument("--wifi-snr-threshold", type=int, default=20)
+    ap.add_argument("--arp-stale-threshold", type=int, default=60)
+    ap.add_argument("--udp-top-ports", type=int, default=20)
+    ap.add_argument("--collect-nat-mappings", action="store_true")
+    ap.add_argument("--time-wait-warn", type=int, default=10000)
+    ap.add_argument("--syn-recv-warn", type=int, default=256)
+    ap.add_argument("--detect-asymmetry", action="store_true")
+    ap.add_argument("--ipvs-top-clients", type=int, default=10)
+    ap.add_argument("--ct-mark-range", default="")
+    ap.add_argument("--influx", action="store_true")
+    ap.add_argument("--influx-tag", action="append", default=[])
+    ap.add_argument("--msgpack", action="store_true")
+    ap.add_argument("--cbor", action="store_true")
+    ap.add_argument("--db", default="")
+    ap.add_argument("--db-prune", default="")
     args = ap.parse_args()
 
     w = Path(args.workdir)
@@ -78,11 +1852,353 @@ def main() -> int:
     analysis.mkdir(parents=True, exist_ok=True)
 
     out: Dict[str, Any] = {"collector": "probe.py"}
+    warnings: List[str] = []
 
     out["metrics_summary"] = parse_metrics(w / "meta" / "metrics.tsv")
+    out["conntrack_growth_per_sec"] = conntrack_growth(w / "meta" / "metrics.tsv")
+    out["ip_forward_disabled_at"] = ip_forward_drops(w / "meta" / "metrics.tsv")
+    for ts in out["ip_forward_disabled_at"]:
+        warnings.append(f"CRITICAL: net.ipv4.ip_forward switched from 1 to 0 during the run (at {ts}); routing to WAN stops")
     out["dmesg_signals"] = dmesg_signals(w / "sys" / "dmesg.txt")
+    out["xt_recent_sets"] = parse_xt_recent(w / "sys" / "proc" / "net" / "xt_recent")
+    out["ipvs_services"] = parse_ipvs(w / "sys" / "proc" / "net" / "ip_vs")
+    # /proc/net/ip_vs lists IPv4 and IPv6 services alike (there is no ip_vs6)
+    out["ipvs_virtual_server_count"] = len(out["ipvs_services"])
+    out["ipvs_real_server_count"] = sum(len(svc["real_servers"]) for svc in out["ipvs_services"])
+    # weight 0: real server quiesced (e.g. by a health checker), gets no new connections.
+    # Keyed "PROTO VIP:PORT -> RIP:PORT": one real server can carry a different
+    # weight under each virtual service it belongs to.
+    out["ipvs_weight_distribution"] = {}
+    for svc in out["ipvs_services"]:
+        vip = ct_endpoint(svc, "addr", "port") if svc["port"] else svc["addr"]
+        for rs in svc["real_servers"]:
+            key = f"{svc['proto']} {vip} -> {ct_endpoint(rs, 'addr', 'port')}"
+            out["ipvs_weight_distribution"][key] = rs["weight"]
+    out["ipvs_percpu_stats"] = parse_ipvs_percpu(w / "sys" / "proc" / "net" / "ip_vs_stats_percpu")
+    if (w / "sys" / "proc" / "net" / "ip_vs_conn").exists():
+        out["ipvs_connections"] = parse_ipvs_conns(w / "sys" / "proc" / "net" / "ip_vs_conn")
+        out["ipvs_client_ip_count"] = ipvs_client_counts(out["ipvs_connections"], args.ipvs_top_clients)
+    # -1: IPVS not loaded (no /proc/net/ip_vs_conn)
+    out["ipvs_conn_count"] = to_int(read_text(w / "sys" / "proc" / "net" / "ip_vs_conn_count").strip())
+    out["ipvs_lblc_cache_size"] = lblc_cache_size(w / "sys" / "proc" / "net" / "ip_vs_lblc")
+    out["ipvs_lblcr_cache_size"] = lblc_cache_size(w / "sys" / "proc" / "net" / "ip_vs_lblcr")
+    # seconds an idle LBLC/LBLCR cache entry is kept (mainline exposes only these knobs)
+    out["ipvs_lblc_expiration"] = read_sysctls(w / "sys" / "proc" / "sys" / "net" / "ipv4" / "vs", ["lblc_expiration", "lblcr_expiration"])
+    out["ipvs_apps"] = parse_ipvs_apps(w / "sys" / "proc" / "net" / "ip_vs_app")
+    out["ipvs_sync_state"] = ipvs_sync_state(w / "net" / "ipvs_daemon.txt")
+    out["ipvs_sync_daemon_running"] = out["ipvs_sync_state"] != "none"
+    out["ipvs_timeouts"] = parse_ipvs_timeouts(w / "net" / "ipvs_timeout.txt")
+    # TCP timeout: ipvsadm --timeout (IP_VS_SO_GET_TIMEOUT, the source behind the
+    # requested ip_vs_timeout) first, then the vendor sysctl net.ipv4.vs.timeout_tcp
+    out["ipvs_tcp_timeout"] = out["ipvs_timeouts"]["tcp_timeout_sec"]
+    if out["ipvs_tcp_timeout"] < 0:
+        vs = read_sysctls(w / "sys" / "proc" / "sys" / "net" / "ipv4" / "vs", ["timeout_tcp"])
+        out["ipvs_tcp_timeout"] = to_int(vs.get("timeout_tcp", ""))
+    out["ipv6_flow_labels"] = parse_flow_labels(w / "sys" / "proc" / "net" / "ip6_flowlabel")
+    out["wildcard_listeners"], out["specific_listeners"] = tcp_listeners(w / "sys" / "proc" / "net")
+    out["udp_source_ports"] = udp_source_ports(w / "sys" / "proc" / "net", args.udp_top_ports)
+    out["tcpv4_state_count"] = tcp_state_count(w / "sys" / "proc" / "net" / "tcp")
+    out["tcpv6_state_count"] = tcp_state_count(w / "sys" / "proc" / "net" / "tcp6")
+    tq = tcp_queue_stats(w / "sys" / "proc" / "net")
+    out["tcp_max_rcv_queue"], out["tcp_sock_with_high_rcv_queue"] = tq["max_rcv"], tq["high_rcv"]
+    out["tcp_max_snd_queue"] = tq["max_snd"]
+    v4, v6 = out["tcpv4_state_count"], out["tcpv6_state_count"]
+    out["time_wait_ports"] = v4.get("TIME_WAIT", 0) + v6.get("TIME_WAIT", 0)
+    out["syn_recv_ports"] = sum(v.get(st, 0) for v in (v4, v6) for st in ("SYN_RECV", "NEW_SYN_RECV"))
+    if out["time_wait_ports"] > args.time_wait_warn:
+        warnings.append(f"{out['time_wait_ports']} TCP sockets in TIME_WAIT (threshold {args.time_wait_warn}); local port exhaustion is possible")
+    if out["syn_recv_ports"] > args.syn_recv_warn:
+        warnings.append(f"{out['syn_recv_ports']} TCP sockets in SYN_RECV (threshold {args.syn_recv_warn}); possible SYN flood")
+    owners = parse_socket_inodes(w / "sys" / "proc" / "socket_inodes.tsv") if args.resolve_raw_pids else {}
+    out["raw_sockets"] = raw_sockets(w / "sys" / "proc" / "net", owners)
+    for rs in out["raw_sockets"]:
+        if rs["pid"] >= 0 and rs["comm"] not in RAW_SOCKET_OWNERS:
+            warnings.append(f"Raw socket (proto {rs['protocol']}) owned by unexpected process: pid={rs['pid']} comm={rs['comm']}")
+    out["net_ifaces"] = parse_net_dev(w / "sys" / "proc" / "net" / "dev")
+    missed = parse_tsv_counts(w / "net" / "rx_missed.tsv")
+    for iface in out["net_ifaces"]:
+        iface["rx_missed"] = missed.get(iface["iface"], -1)
+    # ARP retransmit interval per interface in /proc/net/dev (the "default" template is skipped)
+    retrans = parse_tsv_counts(w / "net" / "neigh_retrans_ms.tsv")
+    out["neigh_retrans_ms"] = {i["iface"]: retrans[i["iface"]] for i in out["net_ifaces"] if i["iface"] in retrans}
+    for iface, ms in out["neigh_retrans_ms"].items():
+        if ms > 1000:
+            warnings.append(f"ARP retrans_time_ms on {iface} is {ms} (default 1000); first packets to new hosts will stall")
+    # IPv6 neighbour reachable time; NDM may set WAN and LAN differently
+    reachable = parse_tsv_counts(w / "net" / "ndp_reachable_ms.tsv")
+    out["ndp_reachable_ms"] = {i["iface"]: reachable[i["iface"]] for i in out["net_ifaces"] if i["iface"] in reachable}
+    # "all" and "default" are templates, not interfaces
+    proxy_arp = parse_tsv_counts(w / "net" / "proxy_arp.tsv")
+    out["proxy_arp_interfaces"] = sorted(i for i, v in proxy_arp.items() if v == 1 and i not in ("all", "default"))
+    out["default_gateway_ipv4"] = default_gateway_v4(w / "sys" / "proc" / "net" / "route")
+    out["default_gateway_ipv6"] = default_gateway_v6(w / "sys" / "proc" / "net" / "ipv6_route")
+    out["ipv6_addrs"] = parse_if_inet6(w / "sys" / "proc" / "net" / "if_inet6")
+    # preferred lifetime ran out (often an expired delegated prefix)
+    out["deprecated_ipv6_addrs"] = [a["addr"] for a in out["ipv6_addrs"] if a["deprecated"]]
+    out["ipv6_mroute_cache_entries"] = parse_ip6_mr_cache(w / "sys" / "proc" / "net" / "ip6_mr_cache")
+    out["ipv6_mroute_vifs"] = parse_ip6_mr_vif(w / "sys" / "proc" / "net" / "ip6_mr_vif")
+    # /proc/uptime: "seconds_up seconds_idle"
+    uptime = read_text(w / "sys" / "proc" / "uptime").split()
+    out["ipv6_mcast_bandwidth"] = ipv6_mcast_bandwidth(
+        w / "meta" / "mcast6_bytes.tsv", out["ipv6_mroute_cache_entries"], out["ipv6_mroute_vifs"],
+        to_int(uptime[0].split(".")[0]) if uptime else -1)
+    out["ipv6_routes"] = parse_ipv6_routes(w / "sys" / "proc" / "net" / "ipv6_route")
+    out["ipv6_routes_by_type"] = ipv6_routes_by_type(w / "net" / "ip6_route_all.txt", out["ipv6_routes"])
+    # RTF_REJECT covers blackhole/unreachable/prohibit. Local and anycast routes
+    # carry RTF_NONEXTHOP too, so it only counts for other gateway-less routes.
+    # The per-table null entry (::/0 on lo, metric 0xffffffff) is not configured.
+    out["null_routes_ipv6"] = sorted({
+        f"{r['destination']}/{r['dest_prefix_len']}" for r in out["ipv6_routes"]
+        if ("RTF_REJECT" in r["flag_strings"]
+            or ("RTF_NONEXTHOP" in r["flag_strings"]
+                and not {"RTF_GATEWAY", "RTF_LOCAL", "RTF_ANYCAST"} & set(r["flag_strings"])))
+        and not (r["dest_prefix_len"] == 0 and r["metric"] == 0xFFFFFFFF)
+    })
+    rejects = out["ipv6_routes_by_type"]["unreachable"] + out["ipv6_routes_by_type"]["blackhole"]
+    if rejects > 10:
+        warnings.append(f"{rejects} IPv6 unreachable/blackhole routes installed (routing daemon rejecting prefixes?)")
+    out["network_namespace_inode"], out["other_netns"] = parse_netns(w / "sys" / "proc" / "ns_net.tsv")
+    net = w / "sys" / "proc" / "net"
+    ct_expect = net / "nf_conntrack_expect"
+    if not ct_expect.exists():
+        ct_expect = net / "ip_conntrack_expect"
+    out["conntrack_expects"] = parse_conntrack_expect(ct_expect)
+    # There is no expect-count sysctl; the count is the number of expect entries.
+    exp_max = read_sysctls(w / "sys" / "proc" / "sys" / "net" / "netfilter", ["nf_conntrack_expect_max"])
+    out["conntrack_expect_max"] = to_int(exp_max.get("nf_conntrack_expect_max", ""))
+    out["conntrack_expect_count"] = len(out["conntrack_expects"])
+    if 0 < out["conntrack_expect_max"] <= out["conntrack_expect_count"]:
+        warnings.append(f"Conntrack expect table is full ({out['conntrack_expect_count']}/{out['conntrack_expect_max']}); ALG (SIP/FTP) connections may fail")
+    out["kernel_protocols"] = parse_protocols(w / "sys" / "proc" / "net" / "protocols")
+    out["route_cache_entries"] = route_cache_entries(w / "sys" / "proc" / "net")
+    # NDP neighbour cache counters (the kernel exposes them as /proc/net/stat/ndisc_cache)
+    out["ndisc_stats"] = sum_stat_table(w / "sys" / "proc" / "net" / "stat" / "ndisc_cache")
+    bonding = w / "sys" / "proc" / "net" / "bonding"
+    out["bonds"] = [parse_bond(p) for p in sorted(bonding.iterdir()) if p.is_file()] if bonding.is_dir() else []
+    out["iface_addrs"] = parse_ip_addr(w / "net" / "ip_addr.txt")
+    neigh = parse_ip_neigh(w / "net" / "ip_neigh.txt")
+    out["ndp_failed"] = [e for e in neigh if ":" in e["addr"] and e["state"] == "FAILED"]
+    # next hop -> MAC from the NDP cache (there is no /proc/net/ndisc_cache table;
+    # `ip neigh` is the source). Keyed "addr%iface": link-local next hops are per link.
+    ndp = {(e["addr"], e["iface"]): e for e in neigh if ":" in e["addr"]}
+    out["ipv6_next_hops"] = {}
+    for r in out["ipv6_routes"]:
+        if r["next_hop"] == "::" or "RTF_GATEWAY" not in r["flag_strings"]:
+            continue
+        key = f"{r['next_hop']}%{r['iface']}"
+        e = ndp.get((r["next_hop"], r["iface"]))
+        out["ipv6_next_hops"][key] = e["lladdr"] if e else ""
+        if e and e["state"] == "FAILED":
+            warnings.append(f"IPv6 next hop {key} is FAILED in the NDP cache; IPv6 upstream path is broken")
+    out["pfkey_socket_count"], out["pfkey_uids"] = parse_pfkey(w / "sys" / "proc" / "net" / "pfkey")
+    out["bridge_fdb_size"] = parse_tsv_counts(w / "net" / "bridge_fdb.tsv")
+    for br, n in out["bridge_fdb_size"].items():
+        if n > 1000:
+            warnings.append(f"Bridge {br} FDB holds {n} MAC entries (MAC flood or very large L2 domain?)")
+    out["wireless"] = parse_wireless(w / "sys" / "proc" / "net" / "wireless")
+    channels = wireless_channels(w / "net")
+    for wl in out["wireless"]:
+        # 0: channel unknown (neither iw nor iwconfig reported it)
+        wl["channel"] = channels.get(wl["iface"], 0)
+    # drivers that do not measure noise report 0 or -256; no SNR can be derived then
+    out["wireless_noisy_interfaces"] = [
+        wl["iface"] for wl in out["wireless"]
+        if -256 < wl["noise_level"] < 0 and wl["signal_level"] - wl["noise_level"] < args.wifi_snr_threshold
+    ]
+    for name in out["wireless_noisy_interfaces"]:
+        warnings.append(f"Wi-Fi interface {name} has SNR below {args.wifi_snr_threshold} dB (interference or distant clients)")
+    # gre entries usually mean PPTP, esp entries IPsec
+    out["conntrack_by_proto"] = {}
+    for proto, n in parse_tsv_counts(w / "sys" / "proc" / "net" / "conntrack_by_proto.tsv").items():
+        # "unknown/50" -> "esp"; numbers without a name stay "unknown/N"
+        if proto.startswith("unknown/"):
+            proto = IP_PROTOS.get(proto[len("unknown/"):], proto)
+        out["conntrack_by_proto"][proto] = out["conntrack_by_proto"].get(proto, 0) + n
+    out["conntrack_helpers"] = read_text(w / "sys" / "proc" / "net" / "conntrack_helpers.txt").split()
+    out["conntrack_zones"] = sorted({to_int(z) for z in read_text(w / "sys" / "proc" / "net" / "conntrack_zones.txt").split()} - {-1})
+    if (w / "sys" / "proc" / "net" / "conntrack_marks.tsv").exists():
+        # all-zero marks while CONNMARK rules exist means they are not matching
+        out["conntrack_mark_distribution"] = parse_tsv_counts(w / "sys" / "proc" / "net" / "conntrack_marks.tsv")
+    out["gre_session_count"] = out["conntrack_by_proto"].get("gre", 0)
+    if "pptp" in out["conntrack_helpers"] and out["gre_session_count"] == 0:
+        warnings.append("PPTP control connections are tracked but there are no GRE sessions (nf_conntrack_proto_gre missing or tunnel down?)")
+    ct_path = w / "sys" / "proc" / "net" / "nf_conntrack"
+    if not ct_path.exists():
+        ct_path = w / "sys" / "proc" / "net" / "ip_conntrack"
+    conntrack = parse_conntrack(ct_path) if ct_path.exists() else []
+    if ct_path.exists():
+        out["conntrack"] = conntrack
+    if ct_path.exists():
+        tcp_to = read_sysctls(
+            w / "sys" / "proc" / "sys" / "net" / "netfilter",
+            [f"nf_conntrack_tcp_timeout_{st}" for st in
+             ("syn_sent", "syn_recv", "established", "fin_wait", "close_wait", "last_ack", "time_wait", "close")],
+        )
+        tcp_to_by_state = {k[len("nf_conntrack_tcp_timeout_"):]: to_int(v) for k, v in tcp_to.items()}
+        # 432000 s (5 days) is the kernel default
+        out["conntrack_tcp_age_buckets"] = tcp_idle_buckets(conntrack, tcp_to_by_state.get("established", 432000))
+        out["conntrack_state_anomalies"] = conntrack_state_anomalies(conntrack, tcp_to_by_state)
+        out["udp_conntrack_age_buckets"] = udp_expiry_buckets(conntrack)
+    if ct_path.exists():
+        out["pptp_sessions"] = pptp_sessions(conntrack)
+        out["ipsec_sas"] = ipsec_sas(conntrack)
+        # entries without "zone=" are in the default zone 0
+        by_zone: Dict[int, int] = {}
+        for e in conntrack:
+            z = to_int(e.get("zone", "0"))
+            by_zone[z] = by_zone.get(z, 0) + 1
+        out["conntrack_by_zone"] = {str(z): n for z, n in sorted(by_zone.items())}
+    if args.ct_mark_range:
+        out["conntrack_mark_validation_errors"] = conntrack_mark_errors(conntrack, args.ct_mark_range)
+        warnings.extend(out["conntrack_mark_validation_errors"])
+    if args.detect_asymmetry:
+        out["asymmetric_flows"] = asymmetric_flows(conntrack)
+    if args.collect_nat_mappings:
+        out["nat_mappings"] = extract_nat_mappings(conntrack)
+    arp = parse_arp(w / "sys" / "proc" / "net" / "arp")
+    out["arp_table_hash"] = arp_fingerprint(arp)
+    out["arp_entry_age_estimates"] = arp_age_estimates(
+        arp, w / "net" / "ip_neigh_stats.txt", parse_tsv_counts(w / "net" / "arp_reachable_ms.tsv"))
+    out["gratuitous_arp_count"], out["duplicate_ips"] = arp_conflicts(arp)
+    # ATF_COM | ATF_PERM: static entries ("ip neigh add ... nud permanent" / arp -s)
+    out["arp_permanent_count"] = sum(1 for a in arp if a["flags"] & 0x6 == 0x6)
+    # above gc_thresh2 the neighbour GC runs every few seconds and may evict live entries
+    gc = read_sysctls(w / "sys" / "proc" / "sys" / "net" / "ipv4" / "neigh" / "default", ["gc_thresh2"])
+    gc_thresh2 = to_int(gc.get("gc_thresh2", ""))
+    out["arp_table_near_full"] = gc_thresh2 > 0 and len(arp) > gc_thresh2 * 0.8
+    if out["arp_table_near_full"]:
+        warnings.append(f"ARP table holds {len(arp)} entries, close to gc_thresh2={gc_thresh2}; valid entries may be evicted")
+    for ip in out["duplicate_ips"]:
+        warnings.append(f"IP address conflict: {ip} is known with several MAC addresses")
+    # -1: no conntrack snapshot (--collect-conntrack), nothing to compare against
+    out["arp_stale_count"] = arp_stale_count(arp, conntrack, args.arp_stale_threshold) if ct_path.exists() else -1
+    out["ipx_sockets"] = ipx_socket_count(w / "sys" / "proc" / "net")
+    if out["ipx_sockets"] > 0:
+        warnings.append(f"{out['ipx_sockets']} IPX socket(s) open; legacy ipx module is loaded and in use")
+    out["pppox_sessions"] = parse_pppoe(w / "sys" / "proc" / "net" / "pppoe")
+    out["ipv6_route_stats"] = parse_rt6_stats(w / "sys" / "proc" / "net" / "rt6_stats")
+    netstat = parse_proc_netstat(w / "sys" / "proc" / "net" / "netstat")
+    out["tcp_ext"] = netstat.get("TcpExt", {})
+    # segments queued out of order: upstream reordering (bonded WAN, ECMP)
+    out["tcp_ofo_queue"] = out["tcp_ext"].get("TCPOFOQueue", -1)
+    # accept queue full: SYNs/ACKs dropped, clients see intermittent connect failures
+    out["listen_backlog_overflows"] = out["tcp_ext"].get("ListenOverflows", -1)
+    out["listen_drops"] = out["tcp_ext"].get("ListenDrops", -1)
+    if out["listen_backlog_overflows"] > 0:
+        warnings.append(f"TCP listen queue overflowed {out['listen_backlog_overflows']} times (raise somaxconn or the service backlog)")
+    out["xfrm_stats"] = parse_kv_counters(w / "sys" / "proc" / "net" / "xfrm_stat")
+    out["xfrm_sa_count"] = xfrm_sa_count(w / "net" / "ip_xfrm_count.txt")
+    out["snmp6_stats"] = parse_kv_counters(w / "sys" / "proc" / "net" / "snmp6")
+    out["iface_snmp6"], out["iface_snmp6_raw"] = parse_dev_snmp6_typed(w / "sys" / "proc" / "net" / "dev_snmp6")
+    out["traffic_imbalance_warnings"] = snmp6_imbalance(out["iface_snmp6_raw"])
+    warnings.extend(out["traffic_imbalance_warnings"])
+    out["softnet_stat"] = parse_softnet_stat(w / "sys" / "proc" / "net" / "softnet_stat")
+    # softnet_stat has no NAPI poll counter; time_squeeze counts net_rx_action
+    # rounds that ran out of budget or time with packets still pending
+    out["napi_time_squeeze"] = {cpu: st["time_squeeze"] for cpu, st in out["softnet_stat"].items()}
+    for cpu, st in out["softnet_stat"].items():
+        if st["dropped"] > 0:
+            warnings.append(f"{cpu} dropped {st['dropped']} packets at the backlog queue (netdev_max_backlog too small or CPU saturated)")
+    out["dev_mcast"] = parse_dev_mcast(w / "sys" / "proc" / "net" / "dev_mcast")
+    out["net_core_settings"] = read_sysctls(
+        w / "sys" / "proc" / "sys" / "net" / "core",
+        ["rmem_max", "wmem_max", "rmem_default", "wmem_default", "somaxconn", "netdev_max_backlog"],
+    )
+    # tcp_mem: "min pressure max" in pages; tcp_rmem/tcp_wmem: "min default max" bytes per socket
+    tcp_buf = read_sysctls(w / "sys" / "proc" / "sys" / "net" / "ipv4", ["tcp_mem", "tcp_rmem", "tcp_wmem"])
+    out["tcp_buffer_settings"] = {k: [to_int(x) for x in v.split()] for k, v in tcp_buf.items()}
+    # accept_ra is ignored while forwarding=1 unless it is set to 2
+    out["net_ipv6_settings"] = read_sysctls(
+        w / "sys" / "proc" / "sys" / "net" / "ipv6" / "conf" / "all",
+        ["forwarding", "accept_ra", "accept_redirects", "autoconf", "hop_limit"],
+    )
+    if args.collect_established:
+        out["established_tcp"] = tcp_connections(w / "sys" / "proc" / "net", args.established_max)
+
+    out["netfilter_rule_counts"] = netfilter_rule_counts(w / "sys" / "proc" / "net", w / "net" / "iptables_save.txt")
+
+    out["netfilter_chain_counts"] = netfilter_chain_counts(w / "net" / "iptables_save.txt", w / "net" / "nft_ruleset.txt")
+    # registered xtables extensions (listed once per revision); IPv6 ones prefixed "ip6:"
+    for kind in ("matches", "targets"):
+        v4 = sorted(set(read_text(w / "sys" / "proc" / "net" / f"ip_tables_{kind}").split()))
+        v6 = sorted(set(read_text(w / "sys" / "proc" / "net" / f"ip6_tables_{kind}").split()))
+        out[f"netfilter_{kind}"] = v4 + ["ip6:" + n for n in v6]
+    out["ip6_tables"] = read_text(w / "sys" / "proc" / "net" / "ip6_tables_names").split()
+    out["driver_info"] = driver_info(w / "sys" / "proc" / "driver")
+    modules = loaded_modules(w / "sys" / "proc" / "modules")
+    out["ebtables_active"], out["ebtables"] = ebtables_tables(w / "sys" / "proc" / "net", modules)
+    v6_matches = read_text(w / "sys" / "proc" / "net" / "ip6_tables_matches").split()
+    v6_targets = read_text(w / "sys" / "proc" / "net" / "ip6_tables_targets").split()
+    # empty match list: ip6_tables not loaded, nothing to compare against
+    out["unmatched_netfilter_modules"] = unmatched_netfilter_modules(modules, v6_matches, v6_targets) if v6_matches else []
+    for m in out["unmatched_netfilter_modules"]:
+        warnings.append(f"Netfilter module {m} is loaded but registered no IPv6 match/target (partial init?)")
+
+    # idle timeouts in seconds; udp_timeout_stream applies once a UDP flow saw replies
+    ct_to = read_sysctls(
+        w / "sys" / "proc" / "sys" / "net" / "netfilter",
+        ["nf_conntrack_tcp_timeout_established", "nf_conntrack_tcp_timeout_time_wait",
+         "nf_conntrack_udp_timeout_stream", "nf_conntrack_udp_timeout"],
+    )
+    out["conntrack_proto_timeouts"] = {k[len("nf_conntrack_"):]: to_int(v) for k, v in ct_to.items()}
+    cap = conntrack_capacity(w / "sys" / "proc" / "sys")
+    out["conntrack_capacity"] = cap
+    if cap["used_pct"] > 80:
+        warnings.append(f"Conntrack table is {cap['used_pct']}% full ({cap['current']}/{cap['max']})")
+    # /proc/net/nf_conntrack lists only confirmed (hashed) entries, while
+    # nf_conntrack_count also includes unconfirmed ones still on the per-CPU
+    # lists; the difference approximates the unconfirmed count (the two are
+    # read moments apart, so small values are noise). -1: no table listing.
+    listed = sum(out["conntrack_by_proto"].values())
+    out["conntrack_unconfirmed"] = max(cap["current"] - listed, 0) if out["conntrack_by_proto"] and cap["current"] >= 0 else -1
+    # None: not checked (no --check-conntrack-resizable or no nf_conntrack_max)
+    resizable = read_text(w / "sys" / "proc" / "conntrack_resizable.txt").strip()
+    out["conntrack_resizable"] = resizable == "1" if resizable else None
+    # Without an explicit setting the kernel derives max from the hash size
+    # (4 x buckets on current kernels, 8 x on some older ones).
+    buckets = to_int(read_sysctls(w / "sys" / "proc" / "sys" / "net" / "netfilter", ["nf_conntrack_buckets"]).get("nf_conntrack_buckets", ""))
+    out["conntrack_auto_max"] = buckets > 0 and cap["max"] in (buckets * 4, buckets * 8)
+    if 0 < cap["max"] < 4096:
+        warnings.append(f"nf_conntrack_max is low ({cap['max']}); busy LANs may exhaust the conntrack table")
+
+    rnd = read_sysctls(w / "sys" / "proc" / "sys" / "kernel" / "random", ["entropy_avail", "poolsize"])
+    out["entropy_avail"] = to_int(rnd.get("entropy_avail", ""))
+    out["entropy_pool_size"] = to_int(rnd.get("poolsize", ""))
+    if 0 <= out["entropy_avail"] < 100:
+        warnings.append(f"Low kernel entropy: entropy_avail={out['entropy_avail']} (TLS/VPN handshakes may stall)")
+
+    # 1 = all SysRq functions, other non-zero values are a bitmask of allowed ones
+    sysrq = to_int(read_sysctls(w / "sys" / "proc" / "sys" / "kernel", ["sysrq"]).get("sysrq", ""))
+    out["sysrq_enabled"] = sysrq > 0
+    if out["sysrq_enabled"]:
+        warnings.append(f"Magic SysRq is enabled (kernel.sysrq={sysrq}); console access can reboot or dump the system")
+
+    restrict = read_sysctls(w / "sys" / "proc" / "sys" / "kernel", ["dmesg_restrict"]).get("dmesg_restrict", "")
+    out["dmesg_restricted"] = to_int(restrict) > 0
+    if restrict == "0":
+        warnings.append("dmesg is readable by unprivileged users (kernel.dmesg_restrict=0); kernel addresses may leak")
+
+    out["cgroup_mem_limits"] = parse_cgroup_memory(w / "sys" / "cgroup_memory.tsv")
+    for cg in out["cgroup_mem_limits"]:
+        if cg["oom_kill_count"] > 0:
+            warnings.append(f"cgroup {cg['cgroup']} hit its memory limit: {cg['oom_kill_count']} OOM kill(s)")
+
+    out["warnings"] = warnings
 
-    (analysis / "python_probe.json").write_text(json.dumps(out, ensure_ascii=False, indent=2) + "\n", encoding="utf-8")
+    payload = json.dumps(out, ensure_ascii=False, indent=2) + "\n"
+    (analysis / "python_probe.json").write_text(payload, encoding="utf-8")
+    if args.db:
+        err = store_snapshot(
+            Path(args.db), datetime.now(timezone.utc).strftime("%Y-%m-%dT%H:%M:%SZ"),
+            read_text(w / "meta" / "hostname.txt").strip() or socket.gethostname(), payload, args.db_prune)
+        if err:
+            print(err, file=sys.stderr)
+    if args.influx:
+        (analysis / "metrics.influx").write_text("\n".join(influx_lines(out, w, args.influx_tag)) + "\n", encoding="utf-8")
+    if args.msgpack:
+        (analysis / "python_probe.msgpack").write_bytes(msgpack_encode(out))
+    if args.cbor:
+        (analysis / "python_probe.cbor").write_bytes(cbor_encode(out))
     return 0
 
 
@@ -0,0 +1,354 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+"""Tests for probe.py (stdlib unittest only): /proc parsers, encoders, parser
+fuzzing and a golden-file run over testdata/workdir.
+
+Run: python3 -m unittest discover -s collectors/py
+     UPDATE_GOLDEN=1 python3 -m unittest discover -s collectors/py  (regenerate testdata/golden)
+"""
+
+from __future__ import annotations
+
+import difflib
+import json
+import os
+import random
+import shutil
+import socket
+import subprocess
+import sys
+import tempfile
+import unittest
+from pathlib import Path
+from typing import Any
+
+HERE = Path(__file__).resolve().parent
+sys.path.insert(0, str(HERE))
+
+import probe  # noqa: E402
+
+TCP_HEADER = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+
+
+def proc_hex(ip: str) -> str:
+    # encode like the kernel: each 32-bit word of the address in host byte order
+    raw = socket.inet_pton(socket.AF_INET6 if ":" in ip else socket.AF_INET, ip)
+    return "".join(f"{int.from_bytes(raw[i:i + 4], sys.byteorder):08X}" for i in range(0, len(raw), 4))
+
+
+def tcp_row(sl: int, local: str, lport: int, remote: str, rport: int, state: str) -> str:
+    return (f"{sl:4}: {proc_hex(local)}:{lport:04X} {proc_hex(remote)}:{rport:04X} {state} "
+            "00000000:00000000 00:00000000 00000000     0        0 1234 1 0000000000000000 100 0 0 10 0\n")
+
+
+class ProcNetTCPTest(unittest.TestCase):
+    def setUp(self) -> None:
+        self.tmp = tempfile.TemporaryDirectory()
+        self.net = Path(self.tmp.name)
+
+    def tearDown(self) -> None:
+        self.tmp.cleanup()
+
+    def write(self, name: str, body: str) -> Path:
+        p = self.net / name
+        p.write_text(body, encoding="utf-8")
+        return p
+
+    def test_ipv4_listen_and_non_listen(self) -> None:
+        self.write("tcp", TCP_HEADER
+                   + tcp_row(0, "0.0.0.0", 22, "0.0.0.0", 0, "0A")
+                   + tcp_row(1, "192.168.1.1", 80, "0.0.0.0", 0, "0A")
+                   + tcp_row(2, "192.168.1.1", 22, "192.168.1.50", 51000, "01"))
+        wildcard, specific = probe.tcp_listeners(self.net)
+        self.assertEqual(wildcard, [{"proto": "tcp", "addr": "0.0.0.0", "port": 22}])
+        self.assertEqual(specific, [{"proto": "tcp", "addr": "192.168.1.1", "port": 80}])
+        self.assertEqual(probe.tcp_state_count(self.net / "tcp"), {"LISTEN": 2, "ESTABLISHED": 1})
+
+    def test_ipv6_32_char_address(self) -> None:
+        self.assertEqual(len(proc_hex("2001:db8::1")), 32)
+        self.write("tcp6", TCP_HEADER
+                   + tcp_row(0, "::", 443, "::", 0, "0A")
+                   + tcp_row(1, "2001:db8::1", 8080, "::", 0, "0A"))
+        wildcard, specific = probe.tcp_listeners(self.net)
+        self.assertEqual(wildcard, [{"proto": "tcp6", "addr": "::", "port": 443}])
+        self.assertEqual(specific, [{"proto": "tcp6", "addr": "2001:db8::1", "port": 8080}])
+        self.assertEqual(probe.hex_endpoint(f"{proc_hex('2001:db8::1')}:1F90"), ("2001:db8::1", 8080))
+
+    def test_short_line_is_skipped(self) -> None:
+        p = self.write("tcp", TCP_HEADER
+                       + f"   0: {proc_hex('0.0.0.0')}:0016 00000000:0000 0A\n"
+                       + tcp_row(1, "0.0.0.0", 53, "0.0.0.0", 0, "0A"))
+        rows = probe.proc_net_sockets(p)
+        self.assertEqual(len(rows), 1)
+        self.assertEqual(probe.hex_endpoint(rows[0][1]), ("0.0.0.0", 53))
+
+    def test_empty_and_missing_file(self) -> None:
+        self.assertEqual(probe.proc_net_sockets(self.write("tcp", "")), [])
+        self.assertEqual(probe.proc_net_sockets(self.net / "tcp6"), [])
+        self.assertEqual(probe.tcp_listeners(self.net), ([], []))
+        self.assertEqual(probe.tcp_state_count(self.net / "tcp"), {})
+
+
+class ReadTextTest(unittest.TestCase):
+    def test_truncates_at_max_bytes(self) -> None:
+        with tempfile.TemporaryDirectory() as d:
+            p = Path(d) / "big"
+            p.write_bytes(b"x" * 5000)
+            self.assertEqual(len(probe.read_text(p, max_bytes=1024)), 1024)
+            self.assertEqual(len(probe.read_text(p)), 5000)
+
+    def test_missing_file_is_empty(self) -> None:
+        self.assertEqual(probe.read_text(Path("/nonexistent/keenetic-maxprobe")), "")
+
+
+class MsgpackEncodeTest(unittest.TestCase):
+    def test_scalars(self) -> None:
+        cases = [
+            (None, "c0"), (False, "c2"), (True, "c3"),
+            (0, "00"), (127, "7f"), (128, "cc80"), (256, "cd0100"), (1 << 16, "ce00010000"),
+            (1 << 32, "cf0000000100000000"), (-1, "ff"), (-32, "e0"), (-33, "d0df"),
+            (-129, "d1ff7f"), (-(1 << 31), "d280000000"), (-(1 << 63), "d38000000000000000"),
+            (1.5, "cb3ff8000000000000"), (1 << 64, "cb43f0000000000000"),
+            ("", "a0"), ("abc", "a3616263"), ("x" * 32, "d920" + "78" * 32),
+        ]
+        for v, want in cases:
+            with self.subTest(v=v):
+                self.assertEqual(probe.msgpack_encode(v).hex(), want)
+
+    def test_containers(self) -> None:
+        self.assertEqual(probe.msgpack_encode([1, "a"]).hex(), "9201a161")
+        self.assertEqual(probe.msgpack_encode({"a": [], "b": {}}).hex(), "82a16190a16280")
+        self.assertEqual(probe.msgpack_encode(list(range(16)))[:3].hex(), "dc0010")
+        self.assertEqual(probe.msgpack_encode({str(i): i for i in range(16)})[:3].hex(), "de0010")
+
+
+class CborEncodeTest(unittest.TestCase):
+    def test_rfc8949_vectors(self) -> None:
+        # RFC 8949 Appendix A, preferred (shortest) encodings
+        cases = [
+            (0, "00"), (23, "17"), (24, "1818"), (1000, "1903e8"), (1000000, "1a000f4240"),
+            (1000000000000, "1b000000e8d4a51000"), (18446744073709551615, "1bffffffffffffffff"),
+            (-1, "20"), (-1000, "3903e7"), (-18446744073709551616, "3bffffffffffffffff"),
+            (0.0, "f90000"), (1.5, "f93e00"), (65504.0, "f97bff"), (100000.0, "fa47c35000"),
+            (1.1, "fb3ff199999999999a"), (float("inf"), "f97c00"), (float("nan"), "f97e00"),
+            (False, "f4"), (True, "f5"), (None, "f6"),
+            ("", "60"), ("IETF", "6449455446"), ("\u00fc", "62c3bc"),
+            ([], "80"), ([1, [2, 3]], "8201820203"),
+        ]
+        for v, want in cases:
+            with self.subTest(v=v):
+                self.assertEqual(probe.cbor_encode(v).hex(), want)
+
+    def test_map_keys_sorted_by_encoding(self) -> None:
+        # shorter keys first, then bytewise: "b" < "aa"
+        self.assertEqual(probe.cbor_encode({"aa": 2, "b": 1}).hex(), "a261620162616102")
+        self.assertEqual(probe.cbor_encode({"b": 1, "aa": 2}), probe.cbor_encode({"aa": 2, "b": 1}))
+
+
+# Seed inputs for the fuzz case: one realistic sample per file, keyed by the
+# path (relative to the fuzz dir) each parser reads.
+FUZZ_SEEDS = {
+    "sockets": {
+        "tcp": TCP_HEADER + tcp_row(0, "0.0.0.0", 22, "0.0.0.0", 0, "0A") + tcp_row(1, "192.168.1.1", 22, "192.168.1.50", 51000, "01"),
+        "tcp6": TCP_HEADER + tcp_row(0, "2001:db8::1", 443, "::", 0, "0A"),
+        "udp": TCP_HEADER + tcp_row(1, "127.0.0.1", 53, "0.0.0.0", 0, "07"),
+        "udp6": TCP_HEADER + tcp_row(1, "::", 5353, "::", 0, "07"),
+        "raw": TCP_HEADER + tcp_row(11, "0.0.0.0", 1, "0.0.0.0", 0, "07"),
+        "raw6": TCP_HEADER + tcp_row(12, "::", 58, "::", 0, "07"),
+        "socket_inodes.tsv": "# pid\tcomm\tinode\n412\tndm\t1234\n",
+    },
+    "conntrack": {
+        "nf_conntrack": (
+            "ipv4     2 tcp      6 431999 ESTABLISHED src=192.168.1.10 dst=1.2.3.4 sport=5000 dport=443 packets=3 bytes=180 "
+            "src=1.2.3.4 dst=10.0.0.2 sport=443 dport=5000 packets=2 bytes=120 [ASSURED] mark=0x10 zone=1 use=2\n"
+            "ipv4     2 udp      17 29 src=192.168.1.11 dst=8.8.8.8 sport=5353 dport=53 [UNREPLIED] "
+            "src=8.8.8.8 dst=10.0.0.2 sport=53 dport=5353 mark=0 use=2\n"
+            "ipv4     2 gre      47 17 timeout=30, stream_timeout=180 src=192.168.1.3 dst=198.51.100.1 srckey=0x0 dstkey=0x1a2b "
+            "src=198.51.100.1 dst=192.168.1.3 srckey=0x1a2b dstkey=0x3c4d mark=0 use=2\n"
+            "ipv4     2 unknown  50 590 src=192.168.1.4 dst=198.51.100.2 spi=0xc0ffee src=198.51.100.2 dst=192.168.1.4 mark=0 use=2\n"
+            "tcp      6 60 SYN_SENT src=10.0.0.1 dst=10.0.0.2 sport=1 dport=2 [UNREPLIED] src=10.0.0.2 dst=10.0.0.1 sport=2 dport=1 use=1\n"
+        ),
+    },
+    "net_dev": {
+        "dev": (
+            "Inter-|   Receive                                                |  Transmit\n"
+            " face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n"
+            "    lo: 4968799     838    0    0    0     0          0         0  4968799     838    0    0    0     0       0          0\n"
+            "  eth0:    1390      20    0    0    0     0          0         3     1531      18    0    0    0     0       0          0\n"
+        ),
+    },
+    "ipvs": {
+        "ip_vs": (
+            "IP Virtual Server version 1.2.1 (size=4096)\nProt LocalAddress:Port Scheduler Flags\n"
+            "  -> RemoteAddress:Port Forward Weight ActiveConn InActConn\nTCP  C0A80001:0050 rr\n"
+            "  -> C0A80002:0050      Masq    1      3          4\nFWM  00000001 wlc\n"
+            "UDP  [2001:0db8:0000:0000:0000:0000:0000:0001]:0035 wlc persistent 300 FFFFFFFF\n"
+            "  -> [2001:0db8:0000:0000:0000:0000:0000:0002]:0035      Route   2      0          1\n"
+        ),
+        "ip_vs_stats_percpu": (
+            "       Total Incoming Outgoing         Incoming         Outgoing\n"
+            "CPU    Conns  Packets  Packets            Bytes            Bytes\n"
+            "  0        A       1F        0              3E8                0\n  ~        A       1F        0              3E8                0\n"
+        ),
+        "ip_vs_conn": (
+            "Pro FromIP   FPrt ToIP     TPrt DestIP   DPrt State       Expires PEName PEData\n"
+            "TCP C0A80164 D431 C0A80001 0050 C0A80002 0050 ESTABLISHED    890\n"
+        ),
+        "ip_vs_app": "prot port    usecnt name\nTCP  21      0      ftp\n",
+        "ip_vs_lblc": "IPVS LBLC table\nC0A80001 C0A80002 10\n",
+        "ipvs_timeout.txt": "Timeout (tcp tcpfin udp): 900 120 300\n",
+        "ipvs_daemon.txt": "master sync daemon (mcast=eth0, syncid=1)\n",
+    },
+    "bond": {
+        "bond0": (
+            "Ethernet Channel Bonding Driver: v3.7.1\n\nBonding Mode: IEEE 802.3ad Dynamic link aggregation\n"
+            "MII Status: up\nLACP rate: slow\nCurrently Active Slave: None\n\n802.3ad info\nActor Key: 9\nPartner Key: 1\n"
+            "Partner Mac Address: 00:11:22:33:44:55\n\nSlave Interface: eth1\nMII Status: up\nAggregator ID: 1\n"
+            "details actor lacp pdu:\n    system priority: 65535\n    port key: 9\n"
+            "details partner lacp pdu:\n    port key: 1\n"
+        ),
+    },
+    "wireless": {
+        "wireless": (
+            "Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE\n"
+            " face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22\n"
+            " wlan0: 0000   70.  -60.  -75.        0      0      0      12      0        0   300\n"
+        ),
+        "iw_dev.txt": "phy#0\n\tInterface wlan0\n\t\tchannel 36 (5180 MHz), width: 80 MHz, center1: 5210 MHz\n",
+        "iwconfig.txt": "ra0       Ralink  ESSID:\"x\"\n          Mode:Managed  Channel=6\nra1  Frequency:2.437 GHz\n",
+    },
+}
+
+FUZZ_JUNK = ["", "-1", "0", "-", ":", "::", "=", "src=", "=1", "[", "]", "[ASSURED]", "->", "~", "sl", "Pro", "prot",
+             "0x", "zz", "FFFFFFFF", "9" * 40, "1e999", "nan", ".", "..", "\x00", "\u00fc", "\ufffd",
+             "Slave Interface:", "lacp pdu:", "Channel=", "Frequency:. GHz", "Frequency:" + "9" * 400 + " GHz"]
+
+
+def fuzz_mutate(rng: random.Random, text: str) -> bytes:
+    # a few line/token level edits, occasionally raw byte damage
+    lines = text.splitlines()
+    for _ in range(rng.randint(1, 6)):
+        op = rng.randrange(6)
+        i = rng.randrange(len(lines)) if lines else 0
+        if op == 0 and lines:
+            del lines[i]
+        elif op == 1 and lines:
+            lines.insert(i, lines[i])
+        elif op == 2 and lines:
+            lines[i] = lines[i][:rng.randrange(len(lines[i]) + 1)]
+        elif op == 3 and lines:
+            toks = lines[i].split(" ")
+            j = rng.randrange(len(toks))
+            choice = rng.randrange(3)
+            if choice == 0:
+                toks[j] = rng.choice(FUZZ_JUNK)
+            elif choice == 1:
+                toks.insert(j, rng.choice(FUZZ_JUNK))
+            else:
+                del toks[j]
+            lines[i] = " ".join(toks)
+        elif op == 4:
+            lines.insert(i, " ".join(rng.choice(FUZZ_JUNK) for _ in range(rng.randint(1, 20))))
+        else:
+            lines.insert(i, "".join(chr(rng.randrange(32, 127)) for _ in range(rng.randint(0, 80))))
+    b = bytearray("\n".join(lines).encode("utf-8"))
+    if b and rng.random() < 0.2:
+        for _ in range(rng.randint(1, 4)):
+            b[rng.randrange(len(b))] = rng.randrange(256)
+    return bytes(b)
+
+
+def fuzz_run(target: str, d: Path) -> Any:
+    if target == "sockets":
+        owners = probe.parse_socket_inodes(d / "socket_inodes.tsv")
+        return ([probe.proc_net_sockets(d / n) for n in ("tcp", "tcp6", "udp", "udp6", "raw", "raw6")],
+                probe.tcp_connections(d, 100), probe.udp_source_ports(d, 5), probe.raw_sockets(d, owners),
+                probe.tcp_listeners(d), probe.tcp_state_count(d / "tcp"), probe.tcp_queue_stats(d))
+    if target == "conntrack":
+        entries = probe.parse_conntrack(d / "nf_conntrack")
+        return (entries, probe.extract_nat_mappings(entries), probe.pptp_sessions(entries), probe.ipsec_sas(entries),
+                probe.tcp_idle_buckets(entries, 432000), probe.asymmetric_flows(entries),
+                probe.conntrack_mark_errors(entries, "0-0xff"), probe.udp_expiry_buckets(entries),
+                probe.conntrack_state_anomalies(entries, {"established": 432000, "syn_sent": 120}),
+                probe.arp_stale_count([], entries, 60), [probe.to_int(e.get("zone", "0")) for e in entries])
+    if target == "net_dev":
+        return probe.parse_net_dev(d / "dev")
+    if target == "ipvs":
+        conns = probe.parse_ipvs_conns(d / "ip_vs_conn")
+        return (probe.parse_ipvs(d / "ip_vs"), probe.parse_ipvs_percpu(d / "ip_vs_stats_percpu"), conns,
+                probe.ipvs_client_counts(conns, 3), probe.parse_ipvs_apps(d / "ip_vs_app"),
+                probe.lblc_cache_size(d / "ip_vs_lblc"), probe.parse_ipvs_timeouts(d / "ipvs_timeout.txt"),
+                probe.ipvs_sync_state(d / "ipvs_daemon.txt"))
+    if target == "bond":
+        return probe.parse_bond(d / "bond0")
+    return probe.parse_wireless(d / "wireless"), probe.wireless_channels(d)
+
+
+class ProcParserFuzzTest(unittest.TestCase):
+    # Seeded random damage to the /proc seeds above; every parser must return
+    # (JSON-serialisable) data instead of raising. FUZZ_SEED / FUZZ_ITERATIONS
+    # widen the search; a failure names the seed and iteration to replay.
+    def test_parsers_survive_malformed_input(self) -> None:
+        seed = int(os.environ.get("FUZZ_SEED", "1"))
+        iterations = int(os.environ.get("FUZZ_ITERATIONS", "300"))
+        with tempfile.TemporaryDirectory() as tmp:
+            d = Path(tmp)
+            for target, files in FUZZ_SEEDS.items():
+                rng = random.Random(f"{seed}:{target}")
+                for i in range(iterations):
+                    for name, text in files.items():
+                        # keep some files pristine so mixed good/bad inputs are covered
+                        body = fuzz_mutate(rng, text) if rng.random() < 0.7 else text.encode("utf-8")
+                        (d / name).write_bytes(body)
+                    try:
+                        json.dumps(fuzz_run(target, d))
+                    except Exception as e:  # noqa: BLE001
+                        inputs = {n: (d / n).read_bytes()[:2000] for n in files}
+                        self.fail(f"{target} FUZZ_SEED={seed} iteration {i}: {e!r}\ninputs: {inputs!r}")
+                    for name in files:
+                        (d / name).unlink()
+
+    def test_seeds_parse(self) -> None:
+        # guard against seeds rotting into inputs the parsers silently skip
+        with tempfile.TemporaryDirectory() as tmp:
+            d = Path(tmp)
+            for target, files in FUZZ_SEEDS.items():
+                for name, text in files.items():
+                    (d / name).write_text(text, encoding="utf-8")
+                got = fuzz_run(target, d)
+                with self.subTest(target=target):
+                    self.assertTrue(got[0] if isinstance(got, tuple) else got)
+                for name in files:
+                    (d / name).unlink()
+
+
+
+# Flags for the golden run: the optional collectors on top of the defaults.
+GOLDEN_ARGS = ["--collect-established", "--resolve-raw-pids", "--collect-nat-mappings", "--detect-asymmetry"]
+
+
+@unittest.skipUnless(sys.byteorder == "little", "testdata /proc/net/* addresses are little-endian host order")
+class GoldenProbeTest(unittest.TestCase):
+    # Runs probe.py end to end on testdata/workdir and compares python_probe.json
+    # with testdata/golden/python_probe.json. After an intended output change:
+    #   UPDATE_GOLDEN=1 python3 -m unittest discover -s collectors/py
+    def test_python_probe_json(self) -> None:
+        golden = HERE / "testdata" / "golden" / "python_probe.json"
+        with tempfile.TemporaryDirectory() as tmp:
+            w = Path(tmp) / "workdir"
+            shutil.copytree(HERE / "testdata" / "workdir", w)
+            subprocess.run([sys.executable, str(HERE / "probe.py"), "--workdir", str(w)] + GOLDEN_ARGS,
+                           check=True, capture_output=True)
+            got = (w / "analysis" / "python_probe.json").read_text(encoding="utf-8")
+        if os.environ.get("UPDATE_GOLDEN") == "1":
+            golden.write_text(got, encoding="utf-8")
+            return
+        want = golden.read_text(encoding="utf-8")
+        if got != want:
+            diff = difflib.unified_diff(want.splitlines(), got.splitlines(), "golden", "probe.py", lineterm="")
+            self.fail("python_probe.json differs from the golden file (UPDATE_GOLDEN=1 to accept):\n"
+                      + "\n".join(list(diff)[:80]))
+
+
+if __name__ == "__main__":
+    unittest.main()
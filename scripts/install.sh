@@ -73,6 +73,12 @@ say "[*] Install: $BIN_DIR/keenetic-maxprobe"
 cp -f "$SRC_DIR/bin/keenetic-maxprobe" "$BIN_DIR/keenetic-maxprobe"
 chmod +x "$BIN_DIR/keenetic-maxprobe"
 
+# Stamp build info: GitHub archives carry the commit id in the pax global header.
+BUILD_COMMIT="$(gzip -dc "$ARCHIVE" 2>/dev/null | head -c 1024 | tr -d '\000' | sed -n 's/.*comment=\([0-9a-f]\{7,40\}\).*/\1/p' | cut -c1-12)"
+[ -n "$BUILD_COMMIT" ] || BUILD_COMMIT="unknown"
+BUILD_TIME="$(date -u '+%Y-%m-%dT%H:%M:%SZ' 2>/dev/null || echo unknown)"
+sed -i "s/^BUILD_COMMIT=\"unknown\"/BUILD_COMMIT=\"$BUILD_COMMIT\"/; s/^BUILD_TIME=\"unknown\"/BUILD_TIME=\"$BUILD_TIME\"/" "$BIN_DIR/keenetic-maxprobe" 2>/dev/null || true
+
 # Optional helper
 if [ -f "$SRC_DIR/scripts/entwarectl" ]; then
   say "[*] Install: $BIN_DIR/entwarectl"